@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/docopt/docopt-go"
+	"github.com/kpym/gotoextr/history"
+)
+
+// device is a device of the location history, with the number of its records and their time span
+type device struct {
+	tag, platform string
+	records       int
+	first, last   string
+}
+
+// listDevices returns the devices of the locations, in the order of their first record
+func listDevices(locations <-chan history.Location) []*device {
+	var devices []*device
+	byTag := map[string]*device{}
+	for l := range locations {
+		d := byTag[string(l.DeviceTag)]
+		if d == nil {
+			d = &device{tag: string(l.DeviceTag), first: l.Timestamp, last: l.Timestamp}
+			byTag[d.tag] = d
+			devices = append(devices, d)
+		}
+		d.records++
+		if d.platform == "" {
+			d.platform = l.Platform
+		}
+		if l.Timestamp < d.first {
+			d.first = l.Timestamp
+		}
+		if l.Timestamp > d.last {
+			d.last = l.Timestamp
+		}
+	}
+	return devices
+}
+
+// printDevices prints the devices as a table
+func printDevices(w io.Writer, devices []*device) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Device\tPlatform\tRecords\tFrom\tTo")
+	for _, d := range devices {
+		tag, platform := d.tag, d.platform
+		if tag == "" {
+			tag = "unknown"
+		}
+		if platform == "" {
+			platform = "unknown"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", tag, platform, d.records, d.first, d.last)
+	}
+	tw.Flush()
+}
+
+// devices lists the devices of the location history
+func devices(arguments docopt.Opts) {
+	inputname, err := arguments.String("<input>")
+	check(err)
+	strict, err := arguments.Bool("--strict")
+	check(err)
+
+	// the semantic location history has no devices
+	in := &input{name: inputname, strict: strict}
+	locations, closeInput := in.read()
+	devices := listDevices(locations)
+	check(closeInput())
+	printDevices(os.Stdout, devices)
+	in.printSkipped(os.Stdout)
+}
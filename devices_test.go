@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kpym/gotoextr/history"
+)
+
+func TestListDevices(t *testing.T) {
+	locations := make(chan history.Location, 5)
+	locations <- history.Location{DeviceTag: "-123", Platform: "ANDROID", Timestamp: "2015-01-01T10:00:00Z"}
+	locations <- history.Location{DeviceTag: "456", Timestamp: "2015-01-01T11:00:00Z"}
+	locations <- history.Location{DeviceTag: "-123", Timestamp: "2015-01-02T10:00:00Z"}
+	locations <- history.Location{DeviceTag: "-123", Timestamp: "2014-12-31T10:00:00Z"}
+	locations <- history.Location{Timestamp: "2015-01-03T10:00:00Z"}
+	close(locations)
+	expected := `Device   Platform  Records  From                  To
+-123     ANDROID   3        2014-12-31T10:00:00Z  2015-01-02T10:00:00Z
+456      unknown   1        2015-01-01T11:00:00Z  2015-01-01T11:00:00Z
+unknown  unknown   1        2015-01-03T10:00:00Z  2015-01-03T10:00:00Z
+`
+
+	var b bytes.Buffer
+	printDevices(&b, listDevices(locations))
+	if b.String() != expected {
+		t.Errorf("devices\n%s\nexpected\n%s", b.String(), expected)
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	if got := splitList(" gps, WIFI ,"); strings.Join(got, "|") != "gps|WIFI" {
+		t.Errorf("splitList = %q", got)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// The EXIF tags used to find the time a photo was taken
+const (
+	tagDateTime           = 0x0132 // in IFD0, the file modification time
+	tagExifIFD            = 0x8769 // in IFD0, the offset of the Exif IFD
+	tagDateTimeOriginal   = 0x9003 // in Exif IFD
+	tagOffsetTimeOriginal = 0x9011 // in Exif IFD
+)
+
+// exifTypeSize is the size in bytes of the EXIF value types
+var exifTypeSize = map[uint16]uint32{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// readExifTime returns the time a JPEG photo was taken.
+// It uses DateTimeOriginal and OffsetTimeOriginal from the EXIF data.
+// If there is no offset, the time is considered to be in loc.
+func readExifTime(r io.Reader, loc *time.Location) (time.Time, error) {
+	br := bufio.NewReader(r)
+	// the file should start with the SOI marker
+	var b [4]byte
+	if _, err := io.ReadFull(br, b[:2]); err != nil || b[0] != 0xFF || b[1] != 0xD8 {
+		return time.Time{}, fmt.Errorf("not a JPEG file")
+	}
+	// look for the APP1 segment containing the EXIF data
+	for {
+		if _, err := io.ReadFull(br, b[:]); err != nil {
+			return time.Time{}, err
+		}
+		if b[0] != 0xFF {
+			return time.Time{}, fmt.Errorf("invalid JPEG marker")
+		}
+		// start of scan or end of image : no more metadata
+		if b[1] == 0xDA || b[1] == 0xD9 {
+			return time.Time{}, fmt.Errorf("no EXIF data")
+		}
+		n := int(binary.BigEndian.Uint16(b[2:])) - 2
+		if n < 0 {
+			return time.Time{}, fmt.Errorf("invalid JPEG segment")
+		}
+		if b[1] != 0xE1 {
+			if _, err := br.Discard(n); err != nil {
+				return time.Time{}, err
+			}
+			continue
+		}
+		segment := make([]byte, n)
+		if _, err := io.ReadFull(br, segment); err != nil {
+			return time.Time{}, err
+		}
+		if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifTime(segment[6:], loc)
+		}
+	}
+}
+
+// exifTime returns the time a photo was taken from the TIFF structure of the EXIF data.
+func exifTime(tiff []byte, loc *time.Location) (time.Time, error) {
+	if len(tiff) < 8 {
+		return time.Time{}, fmt.Errorf("invalid EXIF data")
+	}
+	var bo binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return time.Time{}, fmt.Errorf("invalid EXIF byte order")
+	}
+	ifd0, err := readIFD(tiff, bo, bo.Uint32(tiff[4:]))
+	if err != nil {
+		return time.Time{}, err
+	}
+	// the DateTimeOriginal is in the Exif IFD
+	date, offset := ifd0[tagDateTime], []byte(nil)
+	if ptr := ifd0[tagExifIFD]; len(ptr) == 4 {
+		exif, err := readIFD(tiff, bo, bo.Uint32(ptr))
+		if err != nil {
+			return time.Time{}, err
+		}
+		if exif[tagDateTimeOriginal] != nil {
+			date = exif[tagDateTimeOriginal]
+		}
+		offset = exif[tagOffsetTimeOriginal]
+	}
+	if date == nil {
+		return time.Time{}, fmt.Errorf("no date in EXIF data")
+	}
+	ds, ofs := exifString(date), exifString(offset)
+	if ofs != "" {
+		return time.Parse("2006:01:02 15:04:05-07:00", ds+ofs)
+	}
+	return time.ParseInLocation("2006:01:02 15:04:05", ds, loc)
+}
+
+// readIFD reads the entries of the IFD at offset and returns their raw values by tag.
+func readIFD(tiff []byte, bo binary.ByteOrder, offset uint32) (map[uint16][]byte, error) {
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return nil, fmt.Errorf("invalid IFD offset")
+	}
+	n := uint32(bo.Uint16(tiff[offset:]))
+	if uint64(offset)+2+12*uint64(n) > uint64(len(tiff)) {
+		return nil, fmt.Errorf("invalid IFD size")
+	}
+	tags := make(map[uint16][]byte, n)
+	for i := uint32(0); i < n; i++ {
+		entry := tiff[offset+2+12*i : offset+14+12*i]
+		size := uint64(exifTypeSize[bo.Uint16(entry[2:])]) * uint64(bo.Uint32(entry[4:]))
+		if size <= 4 {
+			tags[bo.Uint16(entry)] = entry[8 : 8+size]
+			continue
+		}
+		start := uint64(bo.Uint32(entry[8:]))
+		if start+size > uint64(len(tiff)) {
+			// ignore the invalid entries
+			continue
+		}
+		tags[bo.Uint16(entry)] = tiff[start : start+size]
+	}
+	return tags, nil
+}
+
+// exifString converts an EXIF ASCII value to string
+func exifString(b []byte) string {
+	return strings.TrimSpace(strings.TrimRight(string(b), "\x00"))
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docopt/docopt-go"
+	"github.com/kpym/gotoextr/history"
+)
+
+// photo is a JPEG file with the (corrected) time it was taken
+type photo struct {
+	path string
+	time time.Time
+	err  error
+}
+
+// findPhotos returns the list of JPEG files in root (a file or a folder)
+func findPhotos(root string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".jpg", ".jpeg":
+			if !d.IsDir() {
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// readPhoto returns the photo at path with its time corrected by the camera clock offset,
+// the time being in the zone tz if the photo has no offset
+func readPhoto(path string, clock time.Duration, tz *time.Location) photo {
+	p := photo{path: path}
+	file, err := os.Open(path)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	defer file.Close()
+	t, err := readExifTime(file, tz)
+	p.time, p.err = t.Add(-clock), err
+	return p
+}
+
+// readTrack reads the locations of the input between from and to selected by the filter
+func readTrack(in *input, filter history.Filter, from, to time.Time) *history.Track {
+	in.start, in.end = from.UTC().Format("2006-01-02"), to.UTC().Format("2006-01-02")
+	locations, closeInput := in.read()
+
+	// the end is included, so the filter ends one second later
+	filter.Start, filter.End = utcBound(from), utcBound(to.Add(time.Second))
+	var track []history.Location
+	for l := range locations {
+		if filter.Accept(l) {
+			track = append(track, l)
+		}
+	}
+	check(closeInput())
+	return history.NewTrack(track)
+}
+
+// geotag finds the position of the photos using the location history
+func geotag(arguments docopt.Opts) {
+	// get the arguments
+	inputname, err := arguments.String("<input>")
+	check(err)
+	photosname, err := arguments.String("<photos>")
+	check(err)
+	filter := parseFilter(arguments)
+	tzname, err := arguments.String("--tz")
+	check(err)
+	tz, err := parseTZ(tzname)
+	check(err)
+	clockstr, err := arguments.String("--clock")
+	check(err)
+	clock, err := time.ParseDuration(clockstr)
+	check(err)
+	gap, err := arguments.Int("--maxgap")
+	check(err)
+	maxGap := time.Duration(gap) * time.Minute
+	sidecars, err := arguments.Bool("--xmp")
+	check(err)
+	overwrite, err := arguments.Bool("--overwrite")
+	check(err)
+	dryRun, err := arguments.Bool("--dry-run")
+	check(err)
+	semantic, err := arguments.Bool("--semantic")
+	check(err)
+	semanticGap, err := arguments.Int("--semantic-gap")
+	check(err)
+	strict, err := arguments.Bool("--strict")
+	check(err)
+
+	// read the photo times
+	paths, err := findPhotos(photosname)
+	check(err)
+	var photos []photo
+	var from, to time.Time
+	for _, path := range paths {
+		p := readPhoto(path, clock, tz)
+		photos = append(photos, p)
+		if p.err != nil {
+			continue
+		}
+		if from.IsZero() || p.time.Before(from) {
+			from = p.time
+		}
+		if to.IsZero() || p.time.After(to) {
+			to = p.time
+		}
+	}
+	if from.IsZero() {
+		check(fmt.Errorf("no dated JPEG photo found in '%s'", photosname))
+	}
+
+	// read the locations around the photos
+	in := &input{name: inputname, semantic: semantic, gap: time.Duration(semanticGap) * time.Minute, strict: strict}
+	track := readTrack(in, filter, from.Add(-maxGap), to.Add(maxGap))
+
+	// match the photos against the track
+	// n : number of photos geotagged
+	// x : number of XMP sidecars written
+	n, x := 0, 0
+	for _, p := range photos {
+		if p.err != nil {
+			fmt.Printf("%s: %v\n", p.path, p.err)
+			continue
+		}
+		l, ok := track.At(p.time, maxGap)
+		if !ok {
+			fmt.Printf("%s: no location within %v of %s\n", p.path, maxGap, p.time.UTC().Format(time.RFC3339))
+			continue
+		}
+		n++
+		fmt.Printf("%s: %s,%s (accuracy %sm) at %s\n", p.path, history.E7toDec(l.LatitudeE7), history.E7toDec(l.LongitudeE7), l.Accuracy, p.time.UTC().Format(time.RFC3339))
+		if !sidecars {
+			continue
+		}
+		// write the XMP sidecar
+		xmp := sidecarName(p.path)
+		if _, err := os.Stat(xmp); err == nil && !overwrite {
+			fmt.Printf("  %s already exists, use --overwrite to replace it\n", xmp)
+			continue
+		}
+		if dryRun {
+			fmt.Printf("  would write %s\n", xmp)
+			continue
+		}
+		if err := writeSidecar(xmp, l, overwrite); err != nil {
+			fmt.Printf("  %v\n", err)
+			continue
+		}
+		x++
+		fmt.Printf("  wrote %s\n", xmp)
+	}
+	in.printSkipped(os.Stdout)
+	fmt.Printf("Geotagged %d of %d photos\n", n, len(photos))
+	if sidecars && !dryRun {
+		fmt.Printf("Wrote %d XMP sidecars\n", x)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kpym/gotoextr/history"
+)
+
+// jpegWithExif builds a minimal JPEG file with DateTimeOriginal and OffsetTimeOriginal
+func jpegWithExif(date, offset string) []byte {
+	le := binary.LittleEndian
+	tiff := []byte("II*\x00")
+	tiff = le.AppendUint32(tiff, 8)
+	// IFD0 at 8 : only the Exif IFD pointer
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = le.AppendUint16(tiff, tagExifIFD)
+	tiff = le.AppendUint16(tiff, 4)
+	tiff = le.AppendUint32(tiff, 1)
+	tiff = le.AppendUint32(tiff, 26)
+	tiff = le.AppendUint32(tiff, 0)
+	// Exif IFD at 26 : DateTimeOriginal at 56 and OffsetTimeOriginal at 76
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = le.AppendUint16(tiff, tagDateTimeOriginal)
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = le.AppendUint32(tiff, 20)
+	tiff = le.AppendUint32(tiff, 56)
+	tiff = le.AppendUint16(tiff, tagOffsetTimeOriginal)
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = le.AppendUint32(tiff, 7)
+	tiff = le.AppendUint32(tiff, 76)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(tiff, date+"\x00"...)
+	tiff = append(tiff, offset+"\x00"...)
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00, 0xFF, 0xE1}
+	jpeg = binary.BigEndian.AppendUint16(jpeg, uint16(len(app1)+2))
+	jpeg = append(jpeg, app1...)
+	return append(jpeg, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+}
+
+func TestReadExifTime(t *testing.T) {
+	data := []struct {
+		date   string
+		offset string
+		out    string
+	}{
+		{"2023:07:14 08:30:12", "+09:00", "2023-07-13T23:30:12Z"},
+		{"2023:07:14 08:30:12", "-01:00", "2023-07-14T09:30:12Z"},
+		{"2023:07:14 08:30:12", "", "2023-07-14T08:30:12Z"},
+	}
+
+	for _, d := range data {
+		got, err := readExifTime(bytes.NewReader(jpegWithExif(d.date, d.offset)), time.UTC)
+		if err != nil {
+			t.Errorf("readExifTime(%s%s) error: %v", d.date, d.offset, err)
+			continue
+		}
+		if got.UTC().Format(time.RFC3339) != d.out {
+			t.Errorf("readExifTime(%s%s) = %s != %s", d.date, d.offset, got.UTC().Format(time.RFC3339), d.out)
+		}
+	}
+
+	if _, err := readExifTime(bytes.NewReader([]byte("not a jpeg")), time.UTC); err == nil {
+		t.Errorf("readExifTime(not a jpeg) should fail")
+	}
+}
+
+func TestReadPhotoZone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "IMG_0001.JPG")
+	if err := os.WriteFile(path, jpegWithExif("2023:07:14 08:30:12", ""), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tokyo := time.FixedZone("Tokyo", 9*3600)
+	// the photo without offset is in the zone, and the camera is one minute ahead
+	p := readPhoto(path, time.Minute, tokyo)
+	if got := p.time.UTC().Format(time.RFC3339); p.err != nil || got != "2023-07-13T23:29:12Z" {
+		t.Errorf("readPhoto = %s, %v", got, p.err)
+	}
+}
+
+func TestReadTrackBounds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Records.json")
+	records := `{"locations":[
+{"latitudeE7":506553765,"longitudeE7":30632229,"accuracy":24,"timestamp":"2023-07-14T08:29:59.900Z"},
+{"latitudeE7":506553865,"longitudeE7":30632329,"accuracy":24,"timestamp":"2023-07-14T08:30:00.352Z"},
+{"latitudeE7":506553965,"longitudeE7":30632429,"accuracy":24,"timestamp":"2023-07-14T08:40:00.352Z"},
+{"latitudeE7":506554065,"longitudeE7":30632529,"accuracy":24,"timestamp":"2023-07-14T08:40:01.000Z"}
+]}`
+	if err := os.WriteFile(path, []byte(records), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2023, 7, 14, 8, 30, 0, 0, time.UTC)
+	// the locations in the first and the last second are kept
+	track := readTrack(&input{name: path}, history.Filter{}, from, from.Add(10*time.Minute))
+	for _, d := range []struct {
+		t     time.Time
+		found bool
+	}{
+		{from.Add(-time.Second), false},
+		{from.Add(352 * time.Millisecond), true},
+		{from.Add(10*time.Minute + 352*time.Millisecond), true},
+		{from.Add(10*time.Minute + 2*time.Second), false},
+	} {
+		if _, found := track.At(d.t, 0); found != d.found {
+			t.Errorf("At(%s) found = %t, expected %t", d.t.Format(time.RFC3339Nano), found, d.found)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The activity recognition of the phone is in the "activity" array of the Records.json locations:
+//
+//	{
+//	  "latitudeE7": 506553765,
+//	  ...
+//	  "activity": [
+//	    {
+//	      "timestamp": "2012-01-27T21:14:40.000Z",
+//	      "activity": [ { "type": "ON_BICYCLE", "confidence": 80 }, { "type": "STILL", "confidence": 20 } ]
+//	    }
+//	  ]
+//	}
+//
+// and in the "activityRecord" signals of the phone export (with a confidence between 0 and 1):
+//
+//	{
+//	  "activityRecord": {
+//	    "probableActivities": [ { "type": "WALKING", "confidence": 0.8 }, ... ],
+//	    "timestamp": "2024-12-07T17:46:20.000+01:00"
+//	  }
+//	}
+
+// The activities of the locations
+const (
+	ActivityStill   = "still"
+	ActivityWalking = "walking"
+	ActivityRunning = "running"
+	ActivityCycling = "cycling"
+	ActivityVehicle = "vehicle"
+)
+
+// activityTypes maps the activity types of the history to the activities.
+// The other types (TILTING, UNKNOWN) are ignored.
+var activityTypes = map[string]string{
+	"STILL":                   ActivityStill,
+	"ON_FOOT":                 ActivityWalking,
+	"WALKING":                 ActivityWalking,
+	"RUNNING":                 ActivityRunning,
+	"ON_BICYCLE":              ActivityCycling,
+	"IN_VEHICLE":              ActivityVehicle,
+	"IN_ROAD_VEHICLE":         ActivityVehicle,
+	"IN_RAIL_VEHICLE":         ActivityVehicle,
+	"IN_FOUR_WHEELER_VEHICLE": ActivityVehicle,
+	"IN_TWO_WHEELER_VEHICLE":  ActivityVehicle,
+	"IN_CAR":                  ActivityVehicle,
+	"IN_BUS":                  ActivityVehicle,
+	"EXITING_VEHICLE":         ActivityVehicle,
+}
+
+// activityTimeout is how long a recognized activity is attached to the next locations
+const activityTimeout = 10 * time.Minute
+
+type probableActivity struct {
+	Type       string  `json:"type"`
+	Confidence float64 `json:"confidence"`
+}
+
+// mostLikely returns the activity with the highest confidence, or "" if none is known
+func mostLikely(activities []probableActivity) string {
+	best, confidence := "", 0.0
+	for _, a := range activities {
+		if activity, ok := activityTypes[a.Type]; ok && (best == "" || a.Confidence > confidence) {
+			best, confidence = activity, a.Confidence
+		}
+	}
+	return best
+}
+
+// activityRecord is an activity recognized at a time
+type activityRecord struct {
+	timestamp string
+	activity  string
+}
+
+// rawActivityRecord is the activityRecord of a rawSignals element
+type rawActivityRecord struct {
+	ProbableActivities []probableActivity `json:"probableActivities"`
+	Timestamp          string             `json:"timestamp"`
+}
+
+// activityCarrier attaches the last recognized activity to the following locations
+// without activity, while it is less than activityTimeout old
+type activityCarrier struct {
+	activity string
+	time     time.Time
+}
+
+// observe records the activity recognized at the timestamp
+func (c *activityCarrier) observe(activity, timestamp string) {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil || activity == "" {
+		return
+	}
+	c.activity, c.time = activity, t
+}
+
+// carry observes the activity of the location, or sets it to the last one
+func (c *activityCarrier) carry(l *Location) {
+	if l.Activity != "" {
+		c.observe(l.Activity, l.Timestamp)
+		return
+	}
+	if c.activity == "" {
+		return
+	}
+	t, err := time.Parse(time.RFC3339, l.Timestamp)
+	if err == nil && !t.Before(c.time) && t.Sub(c.time) <= activityTimeout {
+		l.Activity = c.activity
+	}
+}
+
+// setActivities sets the activities of the locations from the records,
+// both being sorted by time
+func setActivities(locations []Location, records []activityRecord) {
+	var c activityCarrier
+	next := 0
+	for i := range locations {
+		for next < len(records) && records[next].timestamp <= locations[i].Timestamp {
+			c.observe(records[next].activity, records[next].timestamp)
+			next++
+		}
+		c.carry(&locations[i])
+	}
+}
+
+// ParseActivities parses comma separated activities (still, walking, running, cycling, vehicle)
+func ParseActivities(s string) ([]string, error) {
+	var activities []string
+	for _, a := range strings.Split(s, ",") {
+		a = strings.ToLower(strings.TrimSpace(a))
+		switch a {
+		case ActivityStill, ActivityWalking, ActivityRunning, ActivityCycling, ActivityVehicle:
+			activities = append(activities, a)
+		default:
+			return nil, fmt.Errorf("unknown activity %q, expected still, walking, running, cycling or vehicle", a)
+		}
+	}
+	return activities, nil
+}
+
+// activityRule splits when the activity changes.
+// The still locations and the ones without activity keep the previous activity,
+// so a stop at a traffic light doesn't split a ride.
+func activityRule() SplitRule {
+	current := ""
+	moving := func(activity string) bool { return activity != "" && activity != ActivityStill }
+	return func(a, b Location) bool {
+		if current == "" && moving(a.Activity) {
+			current = a.Activity
+		}
+		if !moving(b.Activity) {
+			return false
+		}
+		changed := current != "" && b.Activity != current
+		current = b.Activity
+		return changed
+	}
+}
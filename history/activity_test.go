@@ -0,0 +1,94 @@
+package history
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMostLikely(t *testing.T) {
+	data := []struct {
+		in  []probableActivity
+		out string
+	}{
+		{[]probableActivity{{"ON_BICYCLE", 80}, {"STILL", 20}}, ActivityCycling},
+		{[]probableActivity{{"TILTING", 100}, {"IN_VEHICLE", 30}, {"ON_FOOT", 40}}, ActivityWalking},
+		{[]probableActivity{{"IN_RAIL_VEHICLE", 0.9}}, ActivityVehicle},
+		{[]probableActivity{{"UNKNOWN", 100}}, ""},
+		{nil, ""},
+	}
+
+	for _, d := range data {
+		if got := mostLikely(d.in); got != d.out {
+			t.Errorf("mostLikely(%v) = %s != %s", d.in, got, d.out)
+		}
+	}
+}
+
+func TestReadActivities(t *testing.T) {
+	data := []struct {
+		in  string
+		out []string
+	}{
+		{
+			`{"locations": [
+			{"latitudeE7": 1, "longitudeE7": 1, "timestamp": "2012-01-27T21:00:00Z",
+			 "activity": [{"timestamp": "2012-01-27T21:00:00Z", "activity": [{"type": "ON_BICYCLE", "confidence": 80}]}]},
+			{"latitudeE7": 1, "longitudeE7": 1, "timestamp": "2012-01-27T21:05:00Z"},
+			{"latitudeE7": 1, "longitudeE7": 1, "timestamp": "2012-01-27T21:30:00Z"}]}`,
+			[]string{ActivityCycling, ActivityCycling, ""},
+		},
+		{
+			`{"rawSignals": [
+			{"position": {"LatLng": "50.6°, 3.0°", "timestamp": "2024-12-07T17:40:00.000+01:00"}},
+			{"activityRecord": {"probableActivities": [{"type": "WALKING", "confidence": 0.8}], "timestamp": "2024-12-07T17:46:20.000+01:00"}},
+			{"position": {"LatLng": "50.6°, 3.0°", "timestamp": "2024-12-07T17:46:25.000+01:00"}}]}`,
+			[]string{"", ActivityWalking},
+		},
+	}
+
+	for _, d := range data {
+		locations, err := Read(strings.NewReader(d.in))
+		if err != nil {
+			t.Fatalf("Read() error: %v", err)
+		}
+		var got []string
+		for l := range locations {
+			got = append(got, l.Activity)
+		}
+		if strings.Join(got, ",") != strings.Join(d.out, ",") {
+			t.Errorf("Read(%s) activities = %v != %v", d.in, got, d.out)
+		}
+	}
+}
+
+func TestActivityRule(t *testing.T) {
+	activities := []string{"", ActivityWalking, ActivityStill, ActivityWalking, "", ActivityCycling, ActivityStill, ActivityCycling, ActivityVehicle}
+	expected := []bool{false, false, false, false, true, false, false, true}
+
+	rules, err := ParseSplitRules("2h,activity")
+	if err != nil {
+		t.Fatalf("ParseSplitRules error: %v", err)
+	}
+	for i := range expected {
+		a := Location{Activity: activities[i], Timestamp: "2023-07-14T08:00:00Z"}
+		b := Location{Activity: activities[i+1], Timestamp: "2023-07-14T08:01:00Z"}
+		if got := anyRule(rules, a, b); got != expected[i] {
+			t.Errorf("split from %q to %q = %t != %t", a.Activity, b.Activity, got, expected[i])
+		}
+	}
+}
+
+func TestParseActivities(t *testing.T) {
+	activities, err := ParseActivities("Walking, cycling")
+	if err != nil || strings.Join(activities, ",") != "walking,cycling" {
+		t.Errorf("ParseActivities = %v, %v", activities, err)
+	}
+	if _, err := ParseActivities("walking,flying"); err == nil {
+		t.Errorf("ParseActivities(flying) should fail")
+	}
+
+	f := Filter{Activities: activities}
+	if !f.Accept(Location{Activity: ActivityCycling}) || f.Accept(Location{Activity: ActivityVehicle}) || f.Accept(Location{}) {
+		t.Errorf("the activity filter should accept only walking and cycling")
+	}
+}
@@ -0,0 +1,221 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// point is a position in E7 format
+type point struct {
+	lat, lon int64
+}
+
+// box is a rectangle in E7 format, the bounds included
+type box struct {
+	min, max point
+}
+
+// polygon is an outer ring and its holes, each ring being a list of points
+// (the last point may repeat the first one)
+type polygon [][]point
+
+// Area is a geographic area made of rectangles and polygons with holes.
+// The coordinates are kept in E7 format, so the locations are tested
+// with integer arithmetic, without conversion to floats.
+type Area struct {
+	boxes    []box
+	polygons []polygon
+}
+
+// Contains returns true if the location is in the area.
+// The locations with invalid coordinates are not in any area.
+func (a *Area) Contains(l Location) bool {
+	lat, lon, err := coords(l)
+	if err != nil {
+		return false
+	}
+	return a.contains(point{lat, lon})
+}
+
+// contains returns true if p is in the area
+func (a *Area) contains(p point) bool {
+	for _, b := range a.boxes {
+		if b.min.lat <= p.lat && p.lat <= b.max.lat && b.min.lon <= p.lon && p.lon <= b.max.lon {
+			return true
+		}
+	}
+	for _, poly := range a.polygons {
+		if poly.contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains returns true if p is inside the outer ring and outside the holes
+func (poly polygon) contains(p point) bool {
+	if len(poly) == 0 || !insideRing(poly[0], p) {
+		return false
+	}
+	for _, hole := range poly[1:] {
+		if insideRing(hole, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// insideRing returns true if p is inside the ring, by counting the edges crossed
+// by the ray from p to the east. The products of E7 differences fit in an int64.
+func insideRing(ring []point, p point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.lat > p.lat) == (b.lat > p.lat) {
+			// the edge doesn't cross the latitude of p
+			continue
+		}
+		// p is west of the crossing point if
+		// p.lon - a.lon < (b.lon - a.lon) * (p.lat - a.lat) / (b.lat - a.lat)
+		left := (p.lon - a.lon) * (b.lat - a.lat)
+		right := (b.lon - a.lon) * (p.lat - a.lat)
+		if b.lat > a.lat && left < right || b.lat < a.lat && left > right {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// parseE7 converts a decimal coordinate like "-3.0536723" to E7 format
+func parseE7(s string) (int64, error) {
+	e7, err := coordToIntString(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	v, err := e7.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("invalid coordinate %q", s)
+	}
+	return v, nil
+}
+
+// ParseBBox returns the area of a bounding box "minLon,minLat,maxLon,maxLat" in degrees.
+// If minLon is greater than maxLon the box crosses the antimeridian.
+func ParseBBox(s string) (*Area, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid bounding box %q, expected minLon,minLat,maxLon,maxLat", s)
+	}
+	var v [4]int64
+	for i, part := range parts {
+		var err error
+		if v[i], err = parseE7(part); err != nil {
+			return nil, fmt.Errorf("invalid bounding box %q: %w", s, err)
+		}
+	}
+	minLon, minLat, maxLon, maxLat := v[0], v[1], v[2], v[3]
+	if minLat > maxLat {
+		return nil, fmt.Errorf("invalid bounding box %q, minLat is greater than maxLat", s)
+	}
+	if minLon <= maxLon {
+		return &Area{boxes: []box{{point{minLat, minLon}, point{maxLat, maxLon}}}}, nil
+	}
+	// across the antimeridian
+	return &Area{boxes: []box{
+		{point{minLat, minLon}, point{maxLat, 1800000000}},
+		{point{minLat, -1800000000}, point{maxLat, maxLon}},
+	}}, nil
+}
+
+// geoCoord is a GeoJSON coordinate unmarshalled to E7 format without float conversion
+type geoCoord int64
+
+func (c *geoCoord) UnmarshalJSON(data []byte) error {
+	v, err := parseE7(string(data))
+	*c = geoCoord(v)
+	return err
+}
+
+// geoObject is a GeoJSON geometry, feature or feature collection
+type geoObject struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoObject      `json:"geometry"`
+	Features    []geoObject     `json:"features"`
+}
+
+// toPolygon converts the GeoJSON rings of [lon, lat] positions to a polygon
+func toPolygon(rings [][][]geoCoord) (polygon, error) {
+	var poly polygon
+	for _, r := range rings {
+		var ring []point
+		for _, position := range r {
+			if len(position) < 2 {
+				return nil, fmt.Errorf("invalid position %v", position)
+			}
+			ring = append(ring, point{lat: int64(position[1]), lon: int64(position[0])})
+		}
+		poly = append(poly, ring)
+	}
+	return poly, nil
+}
+
+// addPolygons adds the polygons of the GeoJSON object to the area
+func (a *Area) addPolygons(g *geoObject) error {
+	switch g.Type {
+	case "FeatureCollection":
+		for i := range g.Features {
+			if err := a.addPolygons(&g.Features[i]); err != nil {
+				return err
+			}
+		}
+	case "Feature":
+		if g.Geometry != nil {
+			return a.addPolygons(g.Geometry)
+		}
+	case "Polygon":
+		var rings [][][]geoCoord
+		if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+			return err
+		}
+		poly, err := toPolygon(rings)
+		if err != nil {
+			return err
+		}
+		a.polygons = append(a.polygons, poly)
+	case "MultiPolygon":
+		var polygons [][][][]geoCoord
+		if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return err
+		}
+		for _, rings := range polygons {
+			poly, err := toPolygon(rings)
+			if err != nil {
+				return err
+			}
+			a.polygons = append(a.polygons, poly)
+		}
+	}
+	// the other geometries (points, lines) have no area
+	return nil
+}
+
+// ReadGeoJSONArea returns the area of the Polygon and MultiPolygon geometries
+// of a GeoJSON geometry, feature or feature collection
+func ReadGeoJSONArea(r io.Reader) (*Area, error) {
+	var g geoObject
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+	a := &Area{}
+	if err := a.addPolygons(&g); err != nil {
+		return nil, err
+	}
+	if len(a.polygons) == 0 {
+		return nil, fmt.Errorf("no Polygon or MultiPolygon found")
+	}
+	return a, nil
+}
@@ -0,0 +1,102 @@
+package history
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBBox(t *testing.T) {
+	data := []struct {
+		bbox     string
+		lat, lon IntString
+		out      bool
+	}{
+		{"2.22,48.81,2.47,48.91", "488566000", "23522000", true},
+		{"2.22,48.81,2.47,48.91", "488100000", "22200000", true},
+		{"2.22,48.81,2.47,48.91", "488099999", "22200000", false},
+		{"2.22,48.81,2.47,48.91", "488566000", "24700001", false},
+		{"-3.1, -0.5, -3, 0.5", "0", "-30500000", true},
+		// across the antimeridian
+		{"179,-10,-179,10", "0", "1795000000", true},
+		{"179,-10,-179,10", "0", "-1795000000", true},
+		{"179,-10,-179,10", "0", "0", false},
+		{"2.22,48.81,2.47,48.91", "invalid", "23522000", false},
+	}
+
+	for _, d := range data {
+		area, err := ParseBBox(d.bbox)
+		if err != nil {
+			t.Errorf("ParseBBox(%s) error: %v", d.bbox, err)
+			continue
+		}
+		if got := area.Contains(Location{LatitudeE7: d.lat, LongitudeE7: d.lon}); got != d.out {
+			t.Errorf("ParseBBox(%s) contains %s,%s = %t", d.bbox, d.lat, d.lon, got)
+		}
+	}
+
+	for _, bbox := range []string{"1,2,3", "1,2,3,x", "1,3,2,2"} {
+		if _, err := ParseBBox(bbox); err == nil {
+			t.Errorf("ParseBBox(%s) should fail", bbox)
+		}
+	}
+}
+
+func TestReadGeoJSONArea(t *testing.T) {
+	// a square with a hole in a feature, and a triangle in a multipolygon
+	geojson := `{
+  "type": "FeatureCollection",
+  "features": [
+    { "type": "Feature", "properties": {}, "geometry": { "type": "Point", "coordinates": [5, 5] } },
+    { "type": "Feature", "properties": {}, "geometry": { "type": "Polygon", "coordinates": [
+      [[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]],
+      [[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]]
+    ] } },
+    { "type": "Feature", "properties": {}, "geometry": { "type": "MultiPolygon", "coordinates": [
+      [[[20, 0], [30, 0], [20.0000001, 10.5, 120.5], [20, 0]]]
+    ] } }
+  ]
+}`
+	data := []struct {
+		lat, lon IntString
+		out      bool
+	}{
+		{"10000000", "10000000", true},
+		{"50000000", "50000000", false},
+		{"50000000", "30000000", true},
+		{"-10000000", "10000000", false},
+		{"10000000", "210000000", true},
+		{"90000000", "210000000", true},
+		{"90000000", "250000000", false},
+		// just inside and just outside the west edge of the triangle
+		{"52500000", "200000001", true},
+		{"52500000", "200000000", false},
+	}
+
+	area, err := ReadGeoJSONArea(strings.NewReader(geojson))
+	if err != nil {
+		t.Fatalf("ReadGeoJSONArea error: %v", err)
+	}
+	for _, d := range data {
+		if got := area.Contains(Location{LatitudeE7: d.lat, LongitudeE7: d.lon}); got != d.out {
+			t.Errorf("area contains %s,%s = %t", d.lat, d.lon, got)
+		}
+	}
+
+	for _, geojson := range []string{`{"type": "Point", "coordinates": [1, 2]}`, `{"type": "Polygon", "coordinates": [[[1]]]}`, `[`} {
+		if _, err := ReadGeoJSONArea(strings.NewReader(geojson)); err == nil {
+			t.Errorf("ReadGeoJSONArea(%s) should fail", geojson)
+		}
+	}
+}
+
+func TestFilterArea(t *testing.T) {
+	area, _ := ParseBBox("0,0,1,1")
+	inside := Location{LatitudeE7: "5000000", LongitudeE7: "5000000"}
+	outside := Location{LatitudeE7: "15000000", LongitudeE7: "5000000"}
+	if !(Filter{Area: area}).Accept(inside) || (Filter{Area: area}).Accept(outside) {
+		t.Errorf("Filter with area should accept only the inside location")
+	}
+	if (Filter{Area: area, Outside: true}).Accept(inside) || !(Filter{Area: area, Outside: true}).Accept(outside) {
+		t.Errorf("Filter outside of area should accept only the outside location")
+	}
+}
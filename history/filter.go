@@ -0,0 +1,68 @@
+package history
+
+import (
+	"strings"
+)
+
+// Filter selects the locations by time, accuracy, activity, device, source, platform and area
+type Filter struct {
+	// Start and End are the time range, End excluded, as UTC timestamps
+	// (with or without the Z) or dates in YYYY-MM-DD format. An empty bound is not checked.
+	Start, End string
+	// Accuracy is the maximal accuracy in meters, no limit if empty
+	Accuracy string
+	// Activities are the accepted activities, all the locations if empty
+	Activities []string
+	// Devices, Sources and Platforms are the accepted device tags, sources
+	// and platforms (case insensitive), all the locations if empty
+	Devices, Sources, Platforms []string
+	// Area is the accepted area, all the locations if nil
+	Area *Area
+	// Outside keeps the locations outside of Area instead
+	Outside bool
+}
+
+// Accept returns true if the location is selected by the filter
+func (f Filter) Accept(l Location) bool {
+	if f.Start != "" && l.Timestamp < f.Start {
+		return false
+	}
+	if f.End != "" && l.Timestamp >= f.End {
+		return false
+	}
+	if len(f.Activities) > 0 && !contains(f.Activities, l.Activity) {
+		return false
+	}
+	if len(f.Devices) > 0 && !contains(f.Devices, string(l.DeviceTag)) {
+		return false
+	}
+	if len(f.Sources) > 0 && !contains(f.Sources, l.Source) {
+		return false
+	}
+	if len(f.Platforms) > 0 && !contains(f.Platforms, l.Platform) {
+		return false
+	}
+	if f.Area != nil && f.Area.Contains(l) == f.Outside {
+		return false
+	}
+	return f.Accuracy == "" || AcceptAccuracy(l.Accuracy, f.Accuracy)
+}
+
+// contains returns true if s is in list, ignoring the case
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptAccuracy returns true if the accuracy is less than max
+// it works with strings to avoid number parsing
+func AcceptAccuracy(a IntString, max string) bool {
+	if len(a) != len(max) {
+		return len(a) <= len(max)
+	}
+	return string(a) <= max
+}
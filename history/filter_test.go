@@ -0,0 +1,55 @@
+package history
+
+import (
+	"testing"
+)
+
+func TestAcceptAccuracy(t *testing.T) {
+	data := []struct {
+		a   IntString
+		max string
+		out bool
+	}{
+		{"5", "5", true},
+		{"5", "6", true},
+		{"5", "4", false},
+		{"15", "5", false},
+		{"15", "10", false},
+		{"15", "15", true},
+		{"15", "20", true},
+		{"15", "", false},
+	}
+
+	for _, d := range data {
+		if AcceptAccuracy(d.a, d.max) != d.out {
+			t.Errorf("AcceptAccuracy(%s, %s) != %t", d.a, d.max, d.out)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f := Filter{Start: "2015-01-01", End: "2015-01-02", Accuracy: "20"}
+	data := []struct {
+		l   Location
+		out bool
+	}{
+		{Location{Accuracy: "15", Timestamp: "2015-01-01T00:00:00Z"}, true},
+		{Location{Accuracy: "25", Timestamp: "2015-01-01T00:00:00Z"}, false},
+		{Location{Accuracy: "15", Timestamp: "2014-12-31T23:59:59Z"}, false},
+		{Location{Accuracy: "15", Timestamp: "2015-01-02T00:00:00Z"}, false},
+	}
+
+	for _, d := range data {
+		if f.Accept(d.l) != d.out {
+			t.Errorf("Accept(%v) != %t", d.l, d.out)
+		}
+	}
+	f = Filter{Devices: []string{"-123", "456"}, Sources: []string{"gps"}}
+	if !f.Accept(Location{DeviceTag: "456", Source: "GPS"}) || f.Accept(Location{DeviceTag: "789", Source: "GPS"}) ||
+		f.Accept(Location{DeviceTag: "-123", Source: "WIFI"}) || f.Accept(Location{Source: "GPS"}) {
+		t.Errorf("the filter should accept only the GPS locations of the devices -123 and 456")
+	}
+	if !(Filter{}).Accept(Location{Accuracy: "1000", Timestamp: "2015-01-01T00:00:00Z"}) {
+		t.Errorf("empty filter should accept all the locations")
+	}
+}
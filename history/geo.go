@@ -0,0 +1,31 @@
+package history
+
+import (
+	"math"
+)
+
+// earthRadius is the mean radius of the Earth in meters
+const earthRadius = 6371008.8
+
+// e7toRad converts a coordinate in E7 format to radians
+func e7toRad(e7 int64) float64 {
+	return float64(e7) / 1e7 * math.Pi / 180
+}
+
+// distance returns the haversine distance in meters between two points in E7 format
+func distance(lat1, lon1, lat2, lon2 int64) float64 {
+	rlat1, rlat2 := e7toRad(lat1), e7toRad(lat2)
+	dlat, dlon := rlat2-rlat1, e7toRad(lon2-lon1)
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(rlat1)*math.Cos(rlat2)*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// coords returns the latitude and longitude of the location in E7 format
+func coords(l Location) (lat, lon int64, err error) {
+	lat, err = l.LatitudeE7.Int64()
+	if err != nil {
+		return 0, 0, err
+	}
+	lon, err = l.LongitudeE7.Int64()
+	return lat, lon, err
+}
@@ -0,0 +1,287 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Zone is a privacy zone, a circle or a polygon, with a center where its locations can be snapped
+type Zone struct {
+	center point
+	// radius is the radius of a circle in meters
+	radius float64
+	// area is the polygon, nil for a circle
+	area *Area
+}
+
+// contains returns true if p is in the zone
+func (z Zone) contains(p point) bool {
+	if z.area != nil {
+		return z.area.contains(p)
+	}
+	return distance(z.center.lat, z.center.lon, p.lat, p.lon) <= z.radius
+}
+
+// ParseZones parses circular zones "lat,lon,radius" separated by semicolons,
+// the coordinates being in degrees and the radius in meters
+func ParseZones(s string) ([]Zone, error) {
+	var zones []Zone
+	for _, z := range strings.Split(s, ";") {
+		parts := strings.Split(z, ",")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid zone %q, expected lat,lon,radius", z)
+		}
+		lat, err := parseE7(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid zone %q: %w", z, err)
+		}
+		lon, err := parseE7(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid zone %q: %w", z, err)
+		}
+		radius, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+		if err != nil || radius <= 0 {
+			return nil, fmt.Errorf("invalid zone %q, the radius should be a positive number of meters", z)
+		}
+		zones = append(zones, Zone{center: point{lat, lon}, radius: radius})
+	}
+	return zones, nil
+}
+
+// ReadGeoJSONZones returns a zone for each Polygon of a GeoJSON geometry, feature
+// or feature collection. The center of a zone is the center of its bounding box.
+func ReadGeoJSONZones(r io.Reader) ([]Zone, error) {
+	area, err := ReadGeoJSONArea(r)
+	if err != nil {
+		return nil, err
+	}
+	var zones []Zone
+	for _, poly := range area.polygons {
+		if len(poly) == 0 || len(poly[0]) == 0 {
+			continue
+		}
+		min, max := poly[0][0], poly[0][0]
+		for _, p := range poly[0] {
+			if p.lat < min.lat {
+				min.lat = p.lat
+			}
+			if p.lon < min.lon {
+				min.lon = p.lon
+			}
+			if p.lat > max.lat {
+				max.lat = p.lat
+			}
+			if p.lon > max.lon {
+				max.lon = p.lon
+			}
+		}
+		center := point{(min.lat + max.lat) / 2, (min.lon + max.lon) / 2}
+		zones = append(zones, Zone{center: center, area: &Area{polygons: []polygon{poly}}})
+	}
+	return zones, nil
+}
+
+// The separators written before a location, the track including the segment
+const (
+	noSeparator = iota
+	segmentSeparator
+	trackSeparator
+)
+
+// pendingLocation is a location that may be in the approach of a zone,
+// with the separator to write before it and the path length from the first pending location
+type pendingLocation struct {
+	l         Location
+	separator int
+	path      float64
+}
+
+// PrivacyFilter is a Writer that removes the locations in the privacy zones,
+// or snaps them to the center of their zone (without altitude, speed and heading).
+// The locations less than trim meters along the track before entering or after leaving
+// a zone are also removed, so the zone can't be guessed from the direction of the track.
+// A new segment starts after the removed locations.
+type PrivacyFilter struct {
+	w     Writer
+	zones []Zone
+	snap  bool
+	trim  float64
+	// the separator to write before the next location
+	separator int
+	// started is true once a location is written, as no separator is written before the first one
+	started bool
+	// the locations that may be in the approach of a zone
+	pending []pendingLocation
+	// the last location with coordinates, and the path length since leaving a zone
+	last     point
+	hasLast  bool
+	leaving  bool
+	distance float64
+}
+
+// NewPrivacyFilter returns a PrivacyFilter writing to w
+func NewPrivacyFilter(w Writer, zones []Zone, snap bool, trim float64) *PrivacyFilter {
+	return &PrivacyFilter{w: w, zones: zones, snap: snap, trim: trim}
+}
+
+// zone returns the zone containing p, or nil
+func (f *PrivacyFilter) zone(p point) *Zone {
+	for i := range f.zones {
+		if f.zones[i].contains(p) {
+			return &f.zones[i]
+		}
+	}
+	return nil
+}
+
+// write writes the location preceded by the strongest of the pending separators
+func (f *PrivacyFilter) write(l Location, separator int) error {
+	if separator > f.separator {
+		f.separator = separator
+	}
+	var err error
+	if f.started {
+		switch f.separator {
+		case trackSeparator:
+			err = f.w.WriteNewTrack()
+		case segmentSeparator:
+			err = f.w.WriteNewSegment()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	f.started, f.separator = true, noSeparator
+	return f.w.WriteLocation(l)
+}
+
+// drop removes the location, and starts a new segment after it
+func (f *PrivacyFilter) drop(separator int) {
+	if separator < segmentSeparator {
+		separator = segmentSeparator
+	}
+	if separator > f.separator {
+		f.separator = separator
+	}
+}
+
+// writePending writes the pending locations while they are more than trim meters before the last one
+func (f *PrivacyFilter) writePending(all bool) error {
+	n := 0
+	for ; n < len(f.pending); n++ {
+		if !all && f.pending[len(f.pending)-1].path-f.pending[n].path <= f.trim {
+			break
+		}
+		if err := f.write(f.pending[n].l, f.pending[n].separator); err != nil {
+			return err
+		}
+	}
+	f.pending = f.pending[n:]
+	return nil
+}
+
+func (f *PrivacyFilter) WriteHeader() error {
+	return f.w.WriteHeader()
+}
+
+func (f *PrivacyFilter) WriteLocation(l Location) error {
+	// the separator received before this location
+	separator := f.separator
+	f.separator = noSeparator
+	lat, lon, err := coords(l)
+	if err != nil {
+		// no position to hide
+		f.pending = append(f.pending, pendingLocation{l: l, separator: separator, path: f.pendingPath(0)})
+		return f.writePending(f.trim == 0)
+	}
+	p := point{lat, lon}
+	step := 0.0
+	if f.hasLast {
+		step = distance(f.last.lat, f.last.lon, p.lat, p.lon)
+	}
+	f.last, f.hasLast = p, true
+
+	if z := f.zone(p); z != nil {
+		if !f.leaving && separator < segmentSeparator {
+			// don't join the track to the zone
+			separator = segmentSeparator
+		}
+		// the pending locations are in the approach of the zone
+		for _, pl := range f.pending {
+			f.drop(pl.separator)
+		}
+		f.pending = f.pending[:0]
+		f.leaving, f.distance = true, 0
+		if !f.snap {
+			f.drop(separator)
+			return nil
+		}
+		l.LatitudeE7 = IntString(strconv.FormatInt(z.center.lat, 10))
+		l.LongitudeE7 = IntString(strconv.FormatInt(z.center.lon, 10))
+		l.Altitude, l.Speed, l.Heading, l.VerticalAccuracy = "", "", "", ""
+		return f.write(l, separator)
+	}
+
+	if f.leaving {
+		f.distance += step
+		if f.distance <= f.trim {
+			f.drop(separator)
+			return nil
+		}
+		f.leaving = false
+		if separator < segmentSeparator {
+			// don't join the zone to the track
+			separator = segmentSeparator
+		}
+	}
+	f.pending = append(f.pending, pendingLocation{l: l, separator: separator, path: f.pendingPath(step)})
+	return f.writePending(f.trim == 0)
+}
+
+// pendingPath returns the path length of a new pending location step meters after the last one
+func (f *PrivacyFilter) pendingPath(step float64) float64 {
+	if len(f.pending) == 0 {
+		return 0
+	}
+	return f.pending[len(f.pending)-1].path + step
+}
+
+func (f *PrivacyFilter) WriteNewSegment() error {
+	if f.separator < segmentSeparator {
+		f.separator = segmentSeparator
+	}
+	return nil
+}
+
+func (f *PrivacyFilter) WriteNewTrack() error {
+	f.separator = trackSeparator
+	return nil
+}
+
+func (f *PrivacyFilter) WriteWaypoint(w Waypoint) error {
+	lat, lon, err := coords(Location{LatitudeE7: w.LatitudeE7, LongitudeE7: w.LongitudeE7})
+	if err == nil {
+		if z := f.zone(point{lat, lon}); z != nil {
+			if !f.snap {
+				return nil
+			}
+			w.LatitudeE7 = IntString(strconv.FormatInt(z.center.lat, 10))
+			w.LongitudeE7 = IntString(strconv.FormatInt(z.center.lon, 10))
+		}
+	}
+	return f.w.WriteWaypoint(w)
+}
+
+func (f *PrivacyFilter) WriteFooter() error {
+	// the end of the history is not the approach of a zone
+	if err := f.writePending(true); err != nil {
+		return err
+	}
+	return f.w.WriteFooter()
+}
+
+func (f *PrivacyFilter) Flush() error {
+	return f.w.Flush()
+}
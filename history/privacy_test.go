@@ -0,0 +1,98 @@
+package history
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// positions is a recorder that also records the coordinates of the locations
+type positions struct {
+	recorder
+}
+
+func (p *positions) WriteLocation(l Location) error {
+	p.events = append(p.events, "loc "+l.Timestamp+" "+string(l.LatitudeE7)+","+string(l.LongitudeE7))
+	return nil
+}
+
+func TestPrivacyFilter(t *testing.T) {
+	// eleven locations on the equator, about 111m apart, the sixth one being in the zone
+	var locations []Location
+	for i := 0; i <= 10; i++ {
+		locations = append(locations, Location{LatitudeE7: "0", LongitudeE7: IntString(strconv.Itoa(i * 10000)), Timestamp: strconv.Itoa(i)})
+	}
+	zones, err := ParseZones("0,0.005,60")
+	if err != nil {
+		t.Fatalf("ParseZones error: %v", err)
+	}
+	data := []struct {
+		name  string
+		snap  bool
+		trim  float64
+		track bool
+		out   string
+	}{
+		{"drop", false, 0, false, "header|loc 0 0,0|loc 1 0,10000|loc 2 0,20000|loc 3 0,30000|loc 4 0,40000|segment|loc 6 0,60000|loc 7 0,70000|loc 8 0,80000|loc 9 0,90000|loc 10 0,100000|footer"},
+		{"drop track", false, 0, true, "header|loc 0 0,0|loc 1 0,10000|loc 2 0,20000|loc 3 0,30000|loc 4 0,40000|track|loc 6 0,60000|loc 7 0,70000|loc 8 0,80000|loc 9 0,90000|loc 10 0,100000|footer"},
+		{"drop trim", false, 150, false, "header|loc 0 0,0|loc 1 0,10000|loc 2 0,20000|segment|loc 7 0,70000|loc 8 0,80000|loc 9 0,90000|loc 10 0,100000|footer"},
+		{"snap", true, 0, false, "header|loc 0 0,0|loc 1 0,10000|loc 2 0,20000|loc 3 0,30000|loc 4 0,40000|segment|loc 5 0,50000|segment|loc 6 0,60000|loc 7 0,70000|loc 8 0,80000|loc 9 0,90000|loc 10 0,100000|footer"},
+		{"snap trim", true, 150, false, "header|loc 0 0,0|loc 1 0,10000|loc 2 0,20000|segment|loc 5 0,50000|segment|loc 7 0,70000|loc 8 0,80000|loc 9 0,90000|loc 10 0,100000|footer"},
+	}
+
+	for _, d := range data {
+		p := &positions{}
+		f := NewPrivacyFilter(p, zones, d.snap, d.trim)
+		f.WriteHeader()
+		for i, l := range locations {
+			if d.track && i == 5 {
+				f.WriteNewTrack()
+			}
+			f.WriteLocation(l)
+		}
+		f.WriteFooter()
+		if got := strings.Join(p.events, "|"); got != d.out {
+			t.Errorf("PrivacyFilter %s wrote\n%s\nexpected\n%s", d.name, got, d.out)
+		}
+	}
+}
+
+func TestPrivacyFilterWaypoint(t *testing.T) {
+	zones, _ := ParseZones("0,0,100")
+	r := &recorder{}
+	NewPrivacyFilter(r, zones, false, 0).WriteWaypoint(Waypoint{LatitudeE7: "0", LongitudeE7: "1000"})
+	NewPrivacyFilter(r, zones, true, 0).WriteWaypoint(Waypoint{LatitudeE7: "0", LongitudeE7: "1000"})
+	NewPrivacyFilter(r, zones, false, 0).WriteWaypoint(Waypoint{LatitudeE7: "0", LongitudeE7: "100000"})
+	if got := strings.Join(r.events, "|"); got != "wpt 0,0  |wpt 0,100000  " {
+		t.Errorf("PrivacyFilter waypoints %q", got)
+	}
+}
+
+func TestParseZones(t *testing.T) {
+	zones, err := ParseZones("48.8566,2.3522,300; -33.8688,151.2093,1000")
+	if err != nil || len(zones) != 2 {
+		t.Fatalf("ParseZones = %v, %v", zones, err)
+	}
+	if zones[1].center != (point{-338688000, 1512093000}) || zones[1].radius != 1000 {
+		t.Errorf("ParseZones second zone = %v", zones[1])
+	}
+	for _, s := range []string{"48.8566,2.3522", "48.8566,2.3522,-1", "x,2.3522,300", "48.8566,2.3522,300;"} {
+		if _, err := ParseZones(s); err == nil {
+			t.Errorf("ParseZones(%s) should fail", s)
+		}
+	}
+
+	zones, err = ReadGeoJSONZones(strings.NewReader(`{"type": "MultiPolygon", "coordinates": [
+		[[[0, 0], [2, 0], [2, 1], [0, 1], [0, 0]]],
+		[[[10, 10], [11, 10], [11, 12], [10, 10]]]
+	]}`))
+	if err != nil || len(zones) != 2 {
+		t.Fatalf("ReadGeoJSONZones = %v, %v", zones, err)
+	}
+	if zones[0].center != (point{5000000, 10000000}) || zones[1].center != (point{110000000, 105000000}) {
+		t.Errorf("ReadGeoJSONZones centers %v %v", zones[0].center, zones[1].center)
+	}
+	if !zones[0].contains(point{5000000, 15000000}) || zones[0].contains(point{5000000, 25000000}) {
+		t.Errorf("ReadGeoJSONZones first zone should contain only its points")
+	}
+}
@@ -0,0 +1,646 @@
+// Package history reads the location history exported from Google Maps
+// (Takeout Records.json, Semantic Location History, phone and iOS exports)
+// and writes it as GPX, KML, TCX, CSV or NMEA.
+//
+// The locations are read with a Reader and sent to a Writer,
+// possibly through Writer decorators like Segmenter or StayDetector:
+//
+//	rd := history.NewReader(file)
+//	locations, err := rd.Locations()
+//	...
+//	w := history.NewSegmenter(history.NewGPXWriter(out, false), trackRules, segmentRules)
+//	w.WriteHeader()
+//	for l := range locations {
+//		w.WriteLocation(l)
+//	}
+//	w.WriteFooter()
+//	w.Flush()
+//	if err := rd.Err(); err != nil {
+//		...
+//	}
+package history
+
+// Before 2024
+// Read Records.json and extract history data.
+// The input date is json with the following format
+// {
+// "locations": [
+//
+//	{
+//	  "latitudeE7": 506553765,
+//	  "longitudeE7": 30632229,
+//	  "accuracy": 24,
+//	  "timestamp": "2012-01-27T21:14:42.352Z"
+//	  ...
+//	},
+//	  ...
+//
+// ]
+//
+// The file is very large, so we read it using json.Decoder.
+//
+// Since 2024.
+// We can export the location history from an Android device to a JSON file.
+// The JSON file has the following format:
+//
+//	{
+//	  ...
+//	  "rawSignals": [
+//	    {
+//	      ...
+//	      "position": {
+//	        "LatLng": "50.6443831°, 3.0536723°",
+//	        "accuracyMeters": 13,
+//	        "altitudeMeters": 65.30000305175781,
+//	        "source": "UNKNOWN",
+//	        "timestamp": "2024-12-07T17:46:25.000+01:00",
+//	        "speedMetersPerSecond": 0.0
+//	      }
+//	      ,
+//	      ...
+//	    }
+//	  ]
+//	}
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// IntString is a string that can be unmarshalled from an int (or any json number)
+// This avoid number parsing
+type IntString string
+
+// Location is the struct for the location data
+type Location struct {
+	LatitudeE7  IntString `json:"latitudeE7"`
+	LongitudeE7 IntString `json:"longitudeE7"`
+	Accuracy    IntString `json:"accuracy"`
+	Timestamp   string    `json:"timestamp"`
+	// Source is how the location was obtained (GPS, WIFI, CELL, ...)
+	Source string `json:"source"`
+	// DeviceTag identifies the device that recorded the location and Platform
+	// its platform (ANDROID, IOS, ...), "" if unknown (only Records.json has them)
+	DeviceTag IntString `json:"deviceTag"`
+	Platform  string    `json:"platformType"`
+	// Altitude in meters, Speed in meters per second, Heading in degrees clockwise from the north
+	// and VerticalAccuracy in meters, "" if unknown
+	Altitude         IntString `json:"altitude"`
+	Speed            IntString `json:"velocity"`
+	Heading          IntString `json:"heading"`
+	VerticalAccuracy IntString `json:"verticalAccuracy"`
+	// Activity is the most likely activity (still, walking, running, cycling or vehicle)
+	// recognized by the phone, or "" if it is unknown
+	Activity string `json:"-"`
+	// Offset is the offset of the local time where the location was recorded,
+	// like "+01:00", or "" if it is unknown (only the new formats have it)
+	Offset string `json:"-"`
+}
+
+// LocalTime returns the timestamp in the local time where the location was recorded,
+// like "2024-12-07T17:46:25+01:00", or "" if the offset is unknown
+func (l Location) LocalTime() string {
+	if l.Offset == "" {
+		return ""
+	}
+	t, err := time.Parse(time.RFC3339, l.Timestamp)
+	if err != nil {
+		return ""
+	}
+	// a fixed zone, as time.Parse would use time.Local (and its DST) if it has the same offset
+	secs, err := offsetSeconds(l.Offset)
+	if err != nil {
+		return ""
+	}
+	return t.In(time.FixedZone("", secs)).Format(time.RFC3339)
+}
+
+// offsetSeconds returns the seconds east of UTC of an offset like "+01:00" or "-05:30"
+func offsetSeconds(offset string) (int, error) {
+	if len(offset) != 6 || (offset[0] != '+' && offset[0] != '-') || offset[3] != ':' {
+		return 0, fmt.Errorf("invalid offset %q", offset)
+	}
+	h, err := strconv.Atoi(offset[1:3])
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset %q", offset)
+	}
+	m, err := strconv.Atoi(offset[4:])
+	if err != nil || m >= 60 {
+		return 0, fmt.Errorf("invalid offset %q", offset)
+	}
+	secs := h*3600 + m*60
+	if offset[0] == '-' {
+		secs = -secs
+	}
+	return secs, nil
+}
+
+// Json unmashalling for IntString
+func (i *IntString) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		// unknown value
+		return nil
+	}
+	*i = IntString(data)
+	return nil
+}
+
+// Int64 parses the IntString, for example a coordinate in E7 format
+func (i IntString) Int64() (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(string(i)), 10, 64)
+}
+
+// Float64 parses the IntString as a float, for example an altitude
+func (i IntString) Float64() (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(string(i)), 64)
+}
+
+// floatString returns the number f as an IntString, "" if f is nil.
+// The phone export has float32 numbers written as float64 (65.30000305175781 for 65.3),
+// so they are shortened to the float32 precision.
+func floatString(f *float64) IntString {
+	if f == nil {
+		return ""
+	}
+	return IntString(strconv.FormatFloat(float64(float32(*f)), 'f', -1, 32))
+}
+
+type latlng struct {
+	Latitude  IntString
+	Longitude IntString
+}
+
+type position struct {
+	LatLng    latlng    `json:"LatLng"`
+	Accuracy  IntString `json:"accuracyMeters"`
+	Timestamp string    `json:"timestamp"`
+	Source    string    `json:"source"`
+	Altitude  *float64  `json:"altitudeMeters"`
+	Speed     *float64  `json:"speedMetersPerSecond"`
+}
+
+// coordToIntString converts a string "XX.XXXXXXX°" to an IntString of E7 format
+func coordToIntString(s string) (IntString, error) {
+	// remove the ° and the surrounding spaces
+	s = strings.TrimSpace(strings.TrimSuffix(s, "°"))
+	// split the string in two parts (the decimal part can be missing)
+	parts := strings.Split(s, ".")
+	if len(parts) == 1 {
+		parts = append(parts, "")
+	}
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid coord %s", s)
+	}
+	// normalize the integer part
+	if len(parts[0]) == 0 {
+		parts[0] = "0"
+	}
+	// cut/pad the decimal part to 7 digits
+	if len(parts[1]) == 7 {
+		// nothing to do
+	} else if len(parts[1]) > 7 {
+		parts[1] = parts[1][:7]
+	} else {
+		parts[1] += strings.Repeat("0", 7-len(parts[1]))
+	}
+
+	// return the IntString
+	return IntString(parts[0] + parts[1]), nil
+}
+
+// Json unmashalling for latlng from "XX.XXXXXXX°,YY.YYYYYYY°"
+func (ll *latlng) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid LatLng %s", s)
+	}
+	ll.Latitude, err = coordToIntString(parts[0])
+	if err != nil {
+		return err
+	}
+	ll.Longitude, err = coordToIntString(parts[1])
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// toUTC convert RFC3339 to a string without the timezone ending with "Z"
+// example "2024-12-07T17:46:25.000+01:00" -> "2024-12-07T16:46:25.000Z"
+func toUTC(s string) string {
+	// if string do not contain a timezone (+ or -), return it
+	if !strings.ContainsAny(s, "+-") {
+		return s
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return s
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// timeOffset returns the offset of the RFC3339 timestamp, like "+01:00",
+// or "" if it is in UTC ("Z") or invalid
+// example "2024-12-07T17:46:25.000+01:00" -> "+01:00"
+func timeOffset(s string) string {
+	if strings.HasSuffix(s, "Z") {
+		return ""
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return ""
+	}
+	return t.Format("-07:00")
+}
+
+// toLocation converts a position to a Location
+func (p *position) toLocation() Location {
+	return Location{
+		LatitudeE7:  p.LatLng.Latitude,
+		LongitudeE7: p.LatLng.Longitude,
+		Accuracy:    p.Accuracy,
+		Timestamp:   toUTC(p.Timestamp),
+		Source:      p.Source,
+		Altitude:    floatString(p.Altitude),
+		Speed:       floatString(p.Speed),
+		Offset:      timeOffset(p.Timestamp),
+	}
+}
+
+func getOldLocation(data []byte) ([]Location, error) {
+	var rec struct {
+		Location
+		Activity []struct {
+			Activity []probableActivity `json:"activity"`
+		} `json:"activity"`
+	}
+	err := json.Unmarshal(data, &rec)
+	if err != nil {
+		return nil, err
+	}
+	// the first activity recognition is the closest to the location
+	if len(rec.Activity) > 0 {
+		rec.Location.Activity = mostLikely(rec.Activity[0].Activity)
+	}
+	return []Location{rec.Location}, nil
+}
+
+// getNewLocation returns the location of a rawSignals element,
+// or calls observe with its activity if it is an activity record
+func getNewLocation(data []byte, observe func(activityRecord)) ([]Location, error) {
+	var pos struct {
+		Position       *position          `json:"position"`
+		ActivityRecord *rawActivityRecord `json:"activityRecord"`
+	}
+	err := json.Unmarshal(data, &pos)
+	if err != nil {
+		return nil, err
+	}
+	if record := pos.ActivityRecord; record != nil {
+		if record.Timestamp == "" {
+			return nil, fmt.Errorf("missing timestamp")
+		}
+		observe(activityRecord{timestamp: toUTC(record.Timestamp), activity: mostLikely(record.ProbableActivities)})
+		return nil, nil
+	}
+	if pos.Position == nil {
+		// not a position (wifi scan, ...)
+		return nil, nil
+	}
+	if pos.Position.Timestamp == "" {
+		return nil, fmt.Errorf("missing timestamp")
+	}
+	return []Location{pos.Position.toLocation()}, nil
+}
+
+// LocBufSize is the size of the channel buffers for locations
+const LocBufSize = 100
+
+// RecordError is the error of a malformed record, that is skipped
+// unless the Reader is strict
+type RecordError struct {
+	// Offset is the byte offset of the record in the input
+	Offset int64
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("malformed record at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads the locations of a location history json file.
+// The format (old Records.json, semantic monthly file, phone or iOS export)
+// is detected from the content.
+type Reader struct {
+	// Strict stops the reading at the first malformed record,
+	// instead of skipping it
+	Strict bool
+	// OnSkip, if not nil, is called for each malformed record skipped.
+	// It is called from the reading goroutine.
+	OnSkip func(err *RecordError)
+	// Segments also reads the semanticSegments of the phone export, their locations being
+	// used only more than Gap away from the rawSignals. The rawSignals are then sorted and
+	// sent at the end of the input, otherwise they are sent as they are read.
+	Segments bool
+	Gap      time.Duration
+
+	decoder *json.Decoder
+	err     error
+	skipped int
+}
+
+// NewReader returns a Reader reading from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{decoder: json.NewDecoder(r)}
+}
+
+// syntaxError returns err located at the current offset of the decoder.
+// The decoder can't go on after such an error.
+func (r *Reader) syntaxError(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("invalid json at offset %d: %w", r.decoder.InputOffset(), err)
+}
+
+// readArray reads the json array of the decoder and sends the locations
+// obtained with getLocations from each element of the array
+func (r *Reader) readArray(getLocations func([]byte) ([]Location, error), send func(Location)) error {
+	// Read the array start
+	t, err := r.decoder.Token()
+	if err != nil {
+		return r.syntaxError(err)
+	}
+	if delim, ok := t.(json.Delim); !ok || delim != '[' {
+		return r.syntaxError(fmt.Errorf("expected array start, got %T: %v", t, t))
+	}
+	return r.readElements(getLocations, send)
+}
+
+// readElements reads the elements of the array (whose start is already read)
+// up to the array end and sends the locations obtained with getLocations from each element
+func (r *Reader) readElements(getLocations func([]byte) ([]Location, error), send func(Location)) error {
+	for r.decoder.More() {
+		var raw json.RawMessage
+		if err := r.decoder.Decode(&raw); err != nil {
+			return r.syntaxError(err)
+		}
+		locs, err := getLocations(raw)
+		if err != nil {
+			rerr := &RecordError{Offset: r.decoder.InputOffset() - int64(len(raw)), Err: err}
+			if r.Strict {
+				return rerr
+			}
+			// skip the malformed record
+			r.skipped++
+			if r.OnSkip != nil {
+				r.OnSkip(rerr)
+			}
+			continue
+		}
+		for _, loc := range locs {
+			send(loc)
+		}
+	}
+	// Read the array end
+	if _, err := r.decoder.Token(); err != nil {
+		return r.syntaxError(err)
+	}
+	return nil
+}
+
+// Locations starts reading and returns the channel of the locations read.
+// An error is returned if the input is not a json object or array.
+// The channel is closed at the end of the input or at the first reading error,
+// which is then returned by Err.
+func (r *Reader) Locations() (<-chan Location, error) {
+	decoder := r.decoder
+
+	// Read the object (or array) start
+	t, err := decoder.Token()
+	if err != nil {
+		return nil, r.syntaxError(err)
+	}
+	delim, ok := t.(json.Delim)
+	if !ok || (delim != '{' && delim != '[') {
+		return nil, r.syntaxError(fmt.Errorf("expected object start, got %T: %v", t, t))
+	}
+
+	// Create a channel to send the locations
+	locations := make(chan Location, LocBufSize)
+	send := func(l Location) { locations <- l }
+
+	// The iOS export is an array of timeline entries
+	if delim == '[' {
+		go func() {
+			r.err = r.readElements(getTimelineEntryLocations, send)
+			// Close the channel when done
+			close(locations)
+		}()
+		return locations, nil
+	}
+
+	// Start a goroutine to read the object
+	go func() {
+		r.err = r.readObject(send)
+		// Close the channel when done
+		close(locations)
+	}()
+
+	return locations, nil
+}
+
+// readObject reads the keys of the json object (whose start is already read)
+// and sends the locations of the known ones
+func (r *Reader) readObject(send func(Location)) error {
+	decoder := r.decoder
+	// the rawSignals of the phone export, with the activities of their records
+	var c activityCarrier
+	getSignal := func(data []byte) ([]Location, error) {
+		return getNewLocation(data, func(a activityRecord) { c.observe(a.activity, a.timestamp) })
+	}
+	sendSignal := func(l Location) {
+		c.carry(&l)
+		send(l)
+	}
+	// with the semanticSegments, the two arrays are collected and merged at the end
+	var signals, segments []Location
+	var activities []activityRecord
+	if r.Segments {
+		getSignal = func(data []byte) ([]Location, error) {
+			return getNewLocation(data, func(a activityRecord) { activities = append(activities, a) })
+		}
+		sendSignal = func(l Location) { signals = append(signals, l) }
+	}
+	found := false
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return r.syntaxError(err)
+		}
+		switch key {
+		case "locations":
+			// old format, the activities are carried to the next locations
+			var c activityCarrier
+			err = r.readArray(getOldLocation, func(l Location) {
+				c.carry(&l)
+				send(l)
+			})
+		case "timelineObjects":
+			// semantic location history
+			err = r.readArray(getSemanticLocations, send)
+		case "rawSignals":
+			// new format
+			err = r.readArray(getSignal, sendSignal)
+		case "semanticSegments":
+			// new format, only read if requested
+			if !r.Segments {
+				var skip json.RawMessage
+				if err := decoder.Decode(&skip); err != nil {
+					return r.syntaxError(err)
+				}
+				break
+			}
+			err = r.readArray(getSegmentLocations, func(l Location) { segments = append(segments, l) })
+		default:
+			// skip the other values
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return r.syntaxError(err)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("unknown json version")
+	}
+	if r.Segments {
+		sort.SliceStable(signals, func(i, j int) bool { return signals[i].Timestamp < signals[j].Timestamp })
+		sort.SliceStable(segments, func(i, j int) bool { return segments[i].Timestamp < segments[j].Timestamp })
+		sort.SliceStable(activities, func(i, j int) bool { return activities[i].timestamp < activities[j].timestamp })
+		setActivities(signals, activities)
+		mergeGaps(nextOf(signals), nextOf(segments), r.Gap, send)
+	}
+	return nil
+}
+
+// nextOf returns a function returning the locations one after the other
+func nextOf(locations []Location) func() (Location, bool) {
+	return func() (Location, bool) {
+		if len(locations) == 0 {
+			return Location{}, false
+		}
+		l := locations[0]
+		locations = locations[1:]
+		return l, true
+	}
+}
+
+// Skipped returns the number of malformed records skipped.
+// It should be called after the channel of locations is closed.
+func (r *Reader) Skipped() int {
+	return r.skipped
+}
+
+// Err returns the error that stopped the reading, if any.
+// It should be called after the channel of locations is closed.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Read reads the locations of r and returns them in a channel.
+// It is a shortcut for NewReader(r).Locations() when the reading errors
+// after the start can be ignored.
+func Read(r io.Reader) (<-chan Location, error) {
+	return NewReader(r).Locations()
+}
+
+// Merge merges two channels of locations sorted by time
+// into one channel of locations sorted by time
+func Merge(a, b <-chan Location) <-chan Location {
+	locations := make(chan Location, LocBufSize)
+	go func() {
+		la, oka := <-a
+		lb, okb := <-b
+		for oka || okb {
+			if oka && (!okb || la.Timestamp <= lb.Timestamp) {
+				locations <- la
+				la, oka = <-a
+			} else {
+				locations <- lb
+				lb, okb = <-b
+			}
+		}
+		close(locations)
+	}()
+	return locations
+}
+
+// MergeGaps merges the extra locations into the records, both sorted by time,
+// keeping only the extra locations in the gaps of the records,
+// that is more than gap away from the previous and the next record
+func MergeGaps(records, extra <-chan Location, gap time.Duration) <-chan Location {
+	locations := make(chan Location, LocBufSize)
+	go func() {
+		next := func(c <-chan Location) func() (Location, bool) {
+			return func() (Location, bool) {
+				l, ok := <-c
+				return l, ok
+			}
+		}
+		mergeGaps(next(records), next(extra), gap, func(l Location) { locations <- l })
+		close(locations)
+	}()
+	return locations
+}
+
+// mergeGaps sends the locations of nextRecord and the ones of nextExtra that are in the gaps
+// of the records, both being sorted by time
+func mergeGaps(nextRecord, nextExtra func() (Location, bool), gap time.Duration, send func(Location)) {
+	// last is the last record sent, if any
+	var last Location
+	hasLast := false
+	lr, okr := nextRecord()
+	le, oke := nextExtra()
+	for okr || oke {
+		if okr && (!oke || lr.Timestamp <= le.Timestamp) {
+			send(lr)
+			last, hasLast = lr, true
+			lr, okr = nextRecord()
+			continue
+		}
+		if (!hasLast || apart(last, le, gap)) && (!okr || apart(le, lr, gap)) {
+			send(le)
+		}
+		le, oke = nextExtra()
+	}
+}
+
+// apart returns true if b is more than gap after a
+func apart(a, b Location, gap time.Duration) bool {
+	ta, err := time.Parse(time.RFC3339, a.Timestamp)
+	if err != nil {
+		return false
+	}
+	tb, err := time.Parse(time.RFC3339, b.Timestamp)
+	return err == nil && tb.Sub(ta) > gap
+}
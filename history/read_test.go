@@ -0,0 +1,302 @@
+package history
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestWithoutTimeZone(t *testing.T) {
+	data := []struct {
+		in  string
+		out string
+	}{
+		{"2015-01-01T00:00:00Z", "2015-01-01T00:00:00Z"},
+		{"2015-01-31T23:59:59", "2015-01-31T23:59:59"},
+		{"2015-12-31T23:59:59+03:00", "2015-12-31T20:59:59Z"},
+		{"2015-12-31T23:59:59-03:00", "2016-01-01T02:59:59Z"},
+	}
+
+	for _, d := range data {
+		got := toUTC(d.in)
+		if got != d.out {
+			t.Errorf("withoutTimeZone(%s) = %s != %s", d.in, got, d.out)
+		}
+	}
+}
+
+func TestToLocation(t *testing.T) {
+	data := []struct {
+		in  position
+		out Location
+	}{
+		{
+			position{
+				LatLng:    latlng{"501234567", "87654321"},
+				Accuracy:  "5",
+				Timestamp: "2015-01-01T00:00:00+01:00",
+			},
+			Location{
+				LatitudeE7:  "501234567",
+				LongitudeE7: "87654321",
+				Accuracy:    "5",
+				Timestamp:   "2014-12-31T23:00:00Z",
+				Offset:      "+01:00",
+			},
+		},
+	}
+
+	for _, d := range data {
+		got := d.in.toLocation()
+		if got != d.out {
+			t.Errorf("toLocation(%v) = %v != %v", d.in, got, d.out)
+		}
+	}
+}
+
+func TestLocalTime(t *testing.T) {
+	data := []struct {
+		in  Location
+		out string
+	}{
+		{Location{Timestamp: "2014-12-31T23:00:00Z", Offset: "+01:00"}, "2015-01-01T00:00:00+01:00"},
+		{Location{Timestamp: "2024-07-14T02:30:00.500Z", Offset: "-05:30"}, "2024-07-13T21:00:00-05:30"},
+		{Location{Timestamp: "2014-12-31T23:00:00Z"}, ""},
+		{Location{Timestamp: "invalid", Offset: "+01:00"}, ""},
+	}
+
+	for _, d := range data {
+		if got := d.in.LocalTime(); got != d.out {
+			t.Errorf("LocalTime(%v) = %s != %s", d.in, got, d.out)
+		}
+	}
+	// the local zone of the computer, with the same winter offset, doesn't add its DST
+	cet, err := time.LoadLocation("CET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = cet
+	summer := Location{Timestamp: "2024-07-07T16:46:25Z", Offset: "+01:00"}
+	if got := summer.LocalTime(); got != "2024-07-07T17:46:25+01:00" {
+		t.Errorf("LocalTime(%v) in CET = %s", summer, got)
+	}
+	if offset := timeOffset("2024-12-07T16:46:25.000Z"); offset != "" {
+		t.Errorf("timeOffset of a UTC timestamp = %s", offset)
+	}
+}
+
+func TestReadPhoneExport(t *testing.T) {
+	in := `{
+  "semanticSegments": [
+    {"startTime": "2024-12-07T17:00:00.000+01:00", "endTime": "2024-12-07T19:00:00.000+01:00",
+     "timelinePath": [{"point": "50.6443831°, 3.0536723°", "time": "2024-12-07T17:45:00.000+01:00"}]}
+  ],
+  "rawSignals": [
+    {"position": {"LatLng": "50.6443831°, 3.0536723°", "accuracyMeters": 13, "source": "WIFI", "timestamp": "2024-12-07T17:46:25.000+01:00"}},
+    {"wifiScan": {"deliveryTime": "2024-12-07T17:47:00.000+01:00"}},
+    {"position": {"LatLng": "50.6443831°, 3.0536723°", "accuracyMeters": 13, "source": "GPS", "timestamp": "2024-12-07T17:44:25.000+01:00"}}
+  ],
+  "userLocationProfile": {"frequentPlaces": []}
+}`
+	data := []struct {
+		segments bool
+		gap      time.Duration
+		out      string
+	}{
+		// the rawSignals are streamed
+		{false, 0, "2024-12-07T16:46:25Z WIFI,2024-12-07T16:44:25Z GPS"},
+		{true, 0, "2024-12-07T16:44:25Z GPS,2024-12-07T16:45:00Z TIMELINE_PATH,2024-12-07T16:46:25Z WIFI"},
+		// the timeline point is less than a minute from the raw signals
+		{true, time.Minute, "2024-12-07T16:44:25Z GPS,2024-12-07T16:46:25Z WIFI"},
+	}
+
+	for _, d := range data {
+		var got []string
+		rd := NewReader(strings.NewReader(in))
+		rd.Segments, rd.Gap = d.segments, d.gap
+		locations, err := rd.Locations()
+		if err != nil {
+			t.Fatalf("Locations() error: %v", err)
+		}
+		for l := range locations {
+			got = append(got, l.Timestamp+" "+l.Source)
+		}
+		if strings.Join(got, ",") != d.out {
+			t.Errorf("Read() with segments %t and gap %s = %v != %s", d.segments, d.gap, got, d.out)
+		}
+		// the wifi scan is not a malformed record
+		if rd.Err() != nil || rd.Skipped() != 0 {
+			t.Errorf("Read() error %v, skipped %d", rd.Err(), rd.Skipped())
+		}
+	}
+}
+
+func TestReadMotion(t *testing.T) {
+	data := []struct {
+		in  string
+		out string
+	}{
+		{
+			`{"locations": [{"latitudeE7": 506553765, "longitudeE7": 30632229, "accuracy": 24, "timestamp": "2012-01-27T21:14:42.352Z",
+			"altitude": 65, "velocity": 2, "heading": 271, "verticalAccuracy": 3}]}`,
+			"65 2 271 3",
+		},
+		{
+			`{"locations": [{"latitudeE7": 506553765, "longitudeE7": 30632229, "accuracy": 24, "timestamp": "2012-01-27T21:14:42.352Z",
+			"altitude": null}]}`,
+			"   ",
+		},
+		{
+			`{"rawSignals": [{"position": {"LatLng": "50.6443831°, 3.0536723°", "accuracyMeters": 13, "timestamp": "2024-12-07T17:46:25.000+01:00",
+			"altitudeMeters": 65.30000305175781, "speedMetersPerSecond": 0.0}}]}`,
+			"65.3 0  ",
+		},
+	}
+
+	for _, d := range data {
+		locations, err := Read(strings.NewReader(d.in))
+		if err != nil {
+			t.Fatalf("Read() error: %v", err)
+		}
+		var got []string
+		for l := range locations {
+			got = append(got, strings.Join([]string{string(l.Altitude), string(l.Speed), string(l.Heading), string(l.VerticalAccuracy)}, " "))
+		}
+		if len(got) != 1 || got[0] != d.out {
+			t.Errorf("Read(%s) altitude, speed, heading and vertical accuracy = %q != %q", d.in, got, d.out)
+		}
+	}
+}
+
+func TestReadIOSExport(t *testing.T) {
+	in := `[
+  {"endTime": "2024-12-07T19:00:00.000+01:00", "startTime": "2024-12-07T17:00:00.000+01:00",
+   "visit": {"topCandidate": {"placeLocation": "geo:50.644383,3.053672"}}},
+  {"endTime": "2024-12-07T19:30:00.000+01:00", "startTime": "2024-12-07T19:00:00.000+01:00",
+   "timelinePath": [{"point": "geo:50.65,3.06", "durationMinutesOffsetFromStartTime": "15"}]}
+]`
+	expected := []string{"2024-12-07T16:00:00Z VISIT", "2024-12-07T18:00:00Z VISIT", "2024-12-07T18:15:00Z TIMELINE_PATH"}
+
+	var got []string
+	locations, err := Read(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	for l := range locations {
+		got = append(got, l.Timestamp+" "+l.Source)
+	}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Read() = %v != %v", got, expected)
+	}
+}
+
+func TestCoordToIntString(t *testing.T) {
+	data := []struct {
+		in  string
+		out IntString
+	}{
+		{"50.6443831°", "506443831"},
+		{" 3.0536723°", "30536723"},
+		{"50.644383", "506443830"},
+		{"-0.12345678", "-01234567"},
+		{"3", "30000000"},
+	}
+
+	for _, d := range data {
+		got, err := coordToIntString(d.in)
+		if err != nil || got != d.out {
+			t.Errorf("coordToIntString(%s) = %s, %v != %s", d.in, got, err, d.out)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	send := func(timestamps ...string) <-chan Location {
+		c := make(chan Location, len(timestamps))
+		for _, ts := range timestamps {
+			c <- Location{Timestamp: ts}
+		}
+		close(c)
+		return c
+	}
+	a := send("2022-03-01T08:00:00Z", "2022-03-01T08:02:00Z", "2022-03-01T08:05:00Z")
+	b := send("2022-03-01T08:01:00.000Z", "2022-03-01T08:03:00.000Z")
+	expected := []string{"2022-03-01T08:00:00Z", "2022-03-01T08:01:00.000Z", "2022-03-01T08:02:00Z", "2022-03-01T08:03:00.000Z", "2022-03-01T08:05:00Z"}
+
+	i := 0
+	for l := range Merge(a, b) {
+		if i >= len(expected) || l.Timestamp != expected[i] {
+			t.Errorf("Merge: location %d is %s", i, l.Timestamp)
+		}
+		i++
+	}
+	if i != len(expected) {
+		t.Errorf("Merge: %d locations != %d", i, len(expected))
+	}
+}
+
+func TestMergeGaps(t *testing.T) {
+	send := func(timestamps ...string) <-chan Location {
+		c := make(chan Location, len(timestamps))
+		for _, ts := range timestamps {
+			c <- Location{Timestamp: ts}
+		}
+		close(c)
+		return c
+	}
+	records := send("2022-03-01T08:00:00Z", "2022-03-01T08:02:00Z", "2022-03-01T09:00:00Z")
+	semantic := send("2022-03-01T07:00:00Z", "2022-03-01T07:58:00Z", "2022-03-01T08:01:00Z", "2022-03-01T08:30:00Z", "2022-03-01T08:55:00Z", "2022-03-01T10:00:00Z")
+	// the semantic locations less than 10 minutes from a record are dropped
+	expected := "2022-03-01T07:00:00Z|2022-03-01T08:00:00Z|2022-03-01T08:02:00Z|2022-03-01T08:30:00Z|2022-03-01T09:00:00Z|2022-03-01T10:00:00Z"
+
+	var got []string
+	for l := range MergeGaps(records, semantic, 10*time.Minute) {
+		got = append(got, l.Timestamp)
+	}
+	if strings.Join(got, "|") != expected {
+		t.Errorf("MergeGaps = %s\nexpected %s", strings.Join(got, "|"), expected)
+	}
+}
+
+func TestReaderMalformed(t *testing.T) {
+	in := `{"locations": [{"latitudeE7": 1, "timestamp": "2015-01-01T00:00:00Z"}, 4, {"timestamp": 5}, {"latitudeE7": 2, "timestamp": "2015-01-02T00:00:00Z"}]}`
+	read := func(rd *Reader) int {
+		locations, err := rd.Locations()
+		if err != nil {
+			t.Fatalf("Locations() error: %v", err)
+		}
+		n := 0
+		for range locations {
+			n++
+		}
+		return n
+	}
+
+	// the malformed records are skipped
+	rd := NewReader(strings.NewReader(in))
+	var offsets []int64
+	rd.OnSkip = func(err *RecordError) { offsets = append(offsets, err.Offset) }
+	if n := read(rd); n != 2 || rd.Err() != nil || rd.Skipped() != 2 {
+		t.Errorf("read %d locations, error %v, skipped %d", n, rd.Err(), rd.Skipped())
+	}
+	if len(offsets) != 2 || offsets[0] != 71 || offsets[1] != 74 {
+		t.Errorf("skipped records at offsets %v", offsets)
+	}
+
+	// the strict reader stops at the first malformed record
+	rd = NewReader(strings.NewReader(in))
+	rd.Strict = true
+	var rerr *RecordError
+	if n := read(rd); n != 1 || !errors.As(rd.Err(), &rerr) || rerr.Offset != 71 {
+		t.Errorf("strict read %d locations, error %v", n, rd.Err())
+	}
+
+	// a truncated input is an error
+	rd = NewReader(strings.NewReader(in[:80]))
+	if read(rd); rd.Err() == nil || !strings.Contains(rd.Err().Error(), "offset 80") {
+		t.Errorf("truncated read error %v", rd.Err())
+	}
+}
@@ -0,0 +1,304 @@
+package history
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SplitRule returns true if a new track (or segment) should start between a and b
+type SplitRule func(a, b Location) bool
+
+// sameDigits returns the number of digits in common between two coordinates
+// for example 987654321 which represent 98.7654321 and 987650321 which represent 98.7650321
+// have 3 decimal digits (after the point) in common
+func sameDigits(a, b IntString) int {
+	na := len(a)
+	nb := len(b)
+	if na != nb || na < 7 {
+		// the coordinates are not the same length or invalid IntString
+		return 0
+	}
+	if a[:na-7] != b[:nb-7] {
+		// the integer part is different
+		return 0
+	}
+	n := 0
+	for i := na - 7; i < na; i++ {
+		if a[i] != b[i] {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// digitsRule splits when the coordinates have less than n decimal digits in common
+func digitsRule(n int) SplitRule {
+	return func(a, b Location) bool {
+		return sameDigits(a.LatitudeE7, b.LatitudeE7) < n || sameDigits(a.LongitudeE7, b.LongitudeE7) < n
+	}
+}
+
+// distanceRule splits when the locations are more than meters apart
+func distanceRule(meters float64) SplitRule {
+	return func(a, b Location) bool {
+		alat, alon, err := coords(a)
+		if err != nil {
+			return false
+		}
+		blat, blon, err := coords(b)
+		if err != nil {
+			return false
+		}
+		return distance(alat, alon, blat, blon) > meters
+	}
+}
+
+// gapRule splits when the time between the locations is more than gap
+func gapRule(gap time.Duration) SplitRule {
+	return func(a, b Location) bool {
+		ta, err := time.Parse(time.RFC3339, a.Timestamp)
+		if err != nil {
+			return false
+		}
+		tb, err := time.Parse(time.RFC3339, b.Timestamp)
+		if err != nil {
+			return false
+		}
+		return tb.Sub(ta) > gap
+	}
+}
+
+// ParseSplitRules parses comma separated rules, each rule being one of
+//   - <n> : less than n digits in common (the historical rule)
+//   - <n>m or <n>km : more than n meters or kilometers apart
+//   - <n>min or <n>h : more than n minutes or hours apart
+//   - activity : the activity changes (from walking to cycling for example)
+func ParseSplitRules(s string) ([]SplitRule, error) {
+	var rules []SplitRule
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "activity" {
+			rules = append(rules, activityRule())
+			continue
+		}
+		// split the number and the unit
+		i := strings.IndexFunc(r, func(c rune) bool { return (c < '0' || c > '9') && c != '.' })
+		if i < 0 {
+			i = len(r)
+		}
+		n, err := strconv.ParseFloat(r[:i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q", r)
+		}
+		switch r[i:] {
+		case "":
+			rules = append(rules, digitsRule(int(n)))
+		case "m":
+			rules = append(rules, distanceRule(n))
+		case "km":
+			rules = append(rules, distanceRule(n*1000))
+		case "min":
+			rules = append(rules, gapRule(time.Duration(n*float64(time.Minute))))
+		case "h":
+			rules = append(rules, gapRule(time.Duration(n*float64(time.Hour))))
+		default:
+			return nil, fmt.Errorf("invalid rule %q, unknown unit %q", r, r[i:])
+		}
+	}
+	return rules, nil
+}
+
+// anyRule returns true if one of the rules splits between a and b.
+// All the rules are called, as some of them (activity) follow the locations.
+func anyRule(rules []SplitRule, a, b Location) bool {
+	split := false
+	for _, rule := range rules {
+		if rule(a, b) {
+			split = true
+		}
+	}
+	return split
+}
+
+// Segmenter is a Writer that starts a new track or a new segment
+// before a location that is too far from the previous one
+type Segmenter struct {
+	w         Writer
+	track     []SplitRule
+	segment   []SplitRule
+	last      Location
+	firstDone bool
+}
+
+// NewSegmenter returns a Segmenter writing to w
+func NewSegmenter(w Writer, track, segment []SplitRule) *Segmenter {
+	return &Segmenter{w: w, track: track, segment: segment}
+}
+
+func (s *Segmenter) WriteHeader() error {
+	return s.w.WriteHeader()
+}
+
+func (s *Segmenter) WriteLocation(l Location) error {
+	if s.firstDone {
+		// if it is not the first location, check if the distance from the previous one
+		// requires a new segment or a new track
+		var err error
+		newTrack, newSegment := anyRule(s.track, s.last, l), anyRule(s.segment, s.last, l)
+		if newTrack {
+			err = s.w.WriteNewTrack()
+		} else if newSegment {
+			err = s.w.WriteNewSegment()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.last, s.firstDone = l, true
+	return s.w.WriteLocation(l)
+}
+
+func (s *Segmenter) WriteNewSegment() error {
+	return s.w.WriteNewSegment()
+}
+
+func (s *Segmenter) WriteNewTrack() error {
+	return s.w.WriteNewTrack()
+}
+
+func (s *Segmenter) WriteWaypoint(w Waypoint) error {
+	return s.w.WriteWaypoint(w)
+}
+
+func (s *Segmenter) WriteFooter() error {
+	return s.w.WriteFooter()
+}
+
+func (s *Segmenter) Flush() error {
+	return s.w.Flush()
+}
+
+// Counter is a Writer that counts the locations, segments and tracks
+type Counter struct {
+	w         Writer
+	Locations int
+	Segments  int
+	Tracks    int
+}
+
+// NewCounter returns a Counter writing to w.
+// The first track and the first segment are counted from the start.
+func NewCounter(w Writer) *Counter {
+	return &Counter{w: w, Segments: 1, Tracks: 1}
+}
+
+func (c *Counter) WriteHeader() error {
+	return c.w.WriteHeader()
+}
+
+func (c *Counter) WriteLocation(l Location) error {
+	c.Locations++
+	return c.w.WriteLocation(l)
+}
+
+func (c *Counter) WriteNewSegment() error {
+	c.Segments++
+	return c.w.WriteNewSegment()
+}
+
+func (c *Counter) WriteNewTrack() error {
+	c.Tracks++
+	c.Segments++
+	return c.w.WriteNewTrack()
+}
+
+func (c *Counter) WriteWaypoint(w Waypoint) error {
+	return c.w.WriteWaypoint(w)
+}
+
+func (c *Counter) WriteFooter() error {
+	return c.w.WriteFooter()
+}
+
+func (c *Counter) Flush() error {
+	return c.w.Flush()
+}
+
+// SegmentProcessor is a Writer that keeps the locations of each segment until its end,
+// and then writes the locations returned by process. The waypoints received meanwhile
+// are written after them.
+type SegmentProcessor struct {
+	w       Writer
+	process func(segment []Location) []Location
+	// the locations and the waypoints of the current segment
+	segment   []Location
+	waypoints []Waypoint
+}
+
+// NewSegmentProcessor returns a SegmentProcessor writing to w
+func NewSegmentProcessor(w Writer, process func(segment []Location) []Location) *SegmentProcessor {
+	return &SegmentProcessor{w: w, process: process}
+}
+
+// writeSegment writes the processed locations and the waypoints of the current segment
+func (p *SegmentProcessor) writeSegment() error {
+	if len(p.segment) > 0 {
+		for _, l := range p.process(p.segment) {
+			if err := p.w.WriteLocation(l); err != nil {
+				return err
+			}
+		}
+	}
+	for _, w := range p.waypoints {
+		if err := p.w.WriteWaypoint(w); err != nil {
+			return err
+		}
+	}
+	p.segment, p.waypoints = p.segment[:0], nil
+	return nil
+}
+
+func (p *SegmentProcessor) WriteHeader() error {
+	return p.w.WriteHeader()
+}
+
+func (p *SegmentProcessor) WriteLocation(l Location) error {
+	p.segment = append(p.segment, l)
+	return nil
+}
+
+func (p *SegmentProcessor) WriteNewSegment() error {
+	if err := p.writeSegment(); err != nil {
+		return err
+	}
+	return p.w.WriteNewSegment()
+}
+
+func (p *SegmentProcessor) WriteNewTrack() error {
+	if err := p.writeSegment(); err != nil {
+		return err
+	}
+	return p.w.WriteNewTrack()
+}
+
+func (p *SegmentProcessor) WriteWaypoint(w Waypoint) error {
+	if len(p.segment) > 0 {
+		p.waypoints = append(p.waypoints, w)
+		return nil
+	}
+	return p.w.WriteWaypoint(w)
+}
+
+func (p *SegmentProcessor) WriteFooter() error {
+	if err := p.writeSegment(); err != nil {
+		return err
+	}
+	return p.w.WriteFooter()
+}
+
+func (p *SegmentProcessor) Flush() error {
+	return p.w.Flush()
+}
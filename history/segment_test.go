@@ -0,0 +1,101 @@
+package history
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSameDigits(t *testing.T) {
+	data := []struct {
+		a      IntString
+		b      IntString
+		expect int
+	}{
+		{"987654321", "987654321", 7}, // all digits are the same
+		{"987654321", "987654021", 4}, // the 5th digit is different
+		{"987654321", "987650321", 3}, // the 4th digit is different
+		{"987654321", "980654321", 0}, // the first digit is different
+		{"987654321", "907654321", 0}, // not the same integer part
+		{"987654321", "87654321", 0},  // not the same integer part
+		{"987654321", "7654321", 0},   // not the same integer part
+		{"987654321", "654321", 0},    // not a valid IntString
+		{"654321", "654321", 0},       // not a valid IntString
+	}
+
+	for _, d := range data {
+		if sameDigits(d.a, d.b) != d.expect {
+			t.Errorf("samePrecision(%s, %s) = %d != %d", d.a, d.b, sameDigits(d.a, d.b), d.expect)
+		}
+	}
+}
+
+func TestParseSplitRules(t *testing.T) {
+	a := Location{LatitudeE7: "509999990", LongitudeE7: "30000000", Timestamp: "2023-07-14T08:00:00Z"}
+	b := Location{LatitudeE7: "510000010", LongitudeE7: "30000000", Timestamp: "2023-07-14T08:20:00Z"}
+	data := []struct {
+		rules string
+		split bool
+	}{
+		{"1", true},    // 50.999999 and 51.000001 have no digit in common
+		{"0.1m", true}, // they are ~0.2m apart
+		{"1m", false},
+		{"1km", false},
+		{"10min", true}, // they are 20 minutes apart
+		{"0.5h", false},
+		{"1km,10min", true},
+		{"1km,1h", false},
+	}
+
+	for _, d := range data {
+		rules, err := ParseSplitRules(d.rules)
+		if err != nil {
+			t.Errorf("ParseSplitRules(%s) error: %v", d.rules, err)
+			continue
+		}
+		if anyRule(rules, a, b) != d.split {
+			t.Errorf("ParseSplitRules(%s) split = %t != %t", d.rules, !d.split, d.split)
+		}
+	}
+
+	for _, bad := range []string{"", "km", "10 days", "1,x"} {
+		if _, err := ParseSplitRules(bad); err == nil {
+			t.Errorf("ParseSplitRules(%s) should fail", bad)
+		}
+	}
+}
+
+func TestSegmenter(t *testing.T) {
+	locations := []Location{
+		{LatitudeE7: "500000000", LongitudeE7: "30000000", Timestamp: "2023-07-14T08:00:00Z"},
+		{LatitudeE7: "500001000", LongitudeE7: "30000000", Timestamp: "2023-07-14T08:01:00Z"},
+		{LatitudeE7: "500001000", LongitudeE7: "30000000", Timestamp: "2023-07-14T08:30:00Z"},
+		{LatitudeE7: "510000000", LongitudeE7: "30000000", Timestamp: "2023-07-14T08:31:00Z"},
+	}
+	expected := []string{
+		"header",
+		"loc 2023-07-14T08:00:00Z",
+		"loc 2023-07-14T08:01:00Z",
+		"segment",
+		"loc 2023-07-14T08:30:00Z",
+		"track",
+		"loc 2023-07-14T08:31:00Z",
+		"footer",
+	}
+
+	trackRules, _ := ParseSplitRules("10km")
+	segmentRules, _ := ParseSplitRules("10min")
+	r := &recorder{}
+	c := NewCounter(r)
+	s := NewSegmenter(c, trackRules, segmentRules)
+	s.WriteHeader()
+	for _, l := range locations {
+		s.WriteLocation(l)
+	}
+	s.WriteFooter()
+	if strings.Join(r.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Segmenter wrote\n%s\nexpected\n%s", strings.Join(r.events, "\n"), strings.Join(expected, "\n"))
+	}
+	if c.Locations != 4 || c.Segments != 3 || c.Tracks != 2 {
+		t.Errorf("Counter counted %d locations, %d segments, %d tracks", c.Locations, c.Segments, c.Tracks)
+	}
+}
@@ -0,0 +1,205 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// The Semantic Location History monthly files from Takeout
+// (Semantic Location History/YYYY/YYYY_MONTH.json) have the following format:
+//
+//	{
+//	  "timelineObjects": [
+//	    {
+//	      "activitySegment": {
+//	        "startLocation": { "latitudeE7": 506553765, "longitudeE7": 30632229 },
+//	        "endLocation": { "latitudeE7": 506443831, "longitudeE7": 30536723 },
+//	        "duration": { "startTimestamp": "2022-03-01T08:12:33.123Z", "endTimestamp": "2022-03-01T08:40:02Z" },
+//	        "waypointPath": { "waypoints": [ { "latE7": 506553765, "lngE7": 30632229 }, ... ] },
+//	        "simplifiedRawPath": { "points": [ { "latE7": 506553765, "lngE7": 30632229, "accuracyMeters": 10, "timestamp": "2022-03-01T08:20:00Z" }, ... ] },
+//	        ...
+//	      }
+//	    },
+//	    {
+//	      "placeVisit": {
+//	        "location": { "latitudeE7": 506443831, "longitudeE7": 30536723, ... },
+//	        "duration": { "startTimestamp": "2022-03-01T08:40:02Z", "endTimestamp": "2022-03-01T12:01:00Z" },
+//	        ...
+//	      }
+//	    },
+//	    ...
+//	  ]
+//	}
+//
+// The older files use "startTimestampMs" and "endTimestampMs" (milliseconds since epoch).
+
+// semanticTimeFormat is the format of the timestamps built from the semantic history
+const semanticTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// The sources of the points of the activity segments
+// (the visits and the activities use the same sources as the semantic segments of the phone export)
+const (
+	sourceWaypointPath = "WAYPOINT_PATH"
+	sourceRawPath      = "RAW_PATH"
+)
+
+type semanticObject struct {
+	PlaceVisit      *placeVisit      `json:"placeVisit"`
+	ActivitySegment *activitySegment `json:"activitySegment"`
+}
+
+type placeVisit struct {
+	Location          e7Point          `json:"location"`
+	Duration          semanticDuration `json:"duration"`
+	SimplifiedRawPath rawPath          `json:"simplifiedRawPath"`
+}
+
+type activitySegment struct {
+	StartLocation     e7Point          `json:"startLocation"`
+	EndLocation       e7Point          `json:"endLocation"`
+	Duration          semanticDuration `json:"duration"`
+	WaypointPath      waypointPath     `json:"waypointPath"`
+	SimplifiedRawPath rawPath          `json:"simplifiedRawPath"`
+}
+
+type e7Point struct {
+	LatitudeE7  IntString `json:"latitudeE7"`
+	LongitudeE7 IntString `json:"longitudeE7"`
+}
+
+type semanticDuration struct {
+	StartTimestamp   string    `json:"startTimestamp"`
+	EndTimestamp     string    `json:"endTimestamp"`
+	StartTimestampMs IntString `json:"startTimestampMs"`
+	EndTimestampMs   IntString `json:"endTimestampMs"`
+}
+
+type waypointPath struct {
+	Waypoints []struct {
+		LatE7 IntString `json:"latE7"`
+		LngE7 IntString `json:"lngE7"`
+	} `json:"waypoints"`
+}
+
+type rawPath struct {
+	Points []struct {
+		LatE7          IntString `json:"latE7"`
+		LngE7          IntString `json:"lngE7"`
+		AccuracyMeters IntString `json:"accuracyMeters"`
+		Timestamp      string    `json:"timestamp"`
+		TimestampMs    IntString `json:"timestampMs"`
+	} `json:"points"`
+}
+
+// semanticTime returns the time from a RFC3339 timestamp or from a timestamp in milliseconds
+func semanticTime(timestamp string, ms IntString) (time.Time, error) {
+	if timestamp != "" {
+		return time.Parse(time.RFC3339, timestamp)
+	}
+	// the ms are usually in a json string
+	n, err := strconv.ParseInt(strings.Trim(string(ms), `"`), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %s", ms)
+	}
+	return time.UnixMilli(n).UTC(), nil
+}
+
+// interval returns the start and end times of the duration
+func (d semanticDuration) interval() (start, end time.Time, err error) {
+	start, err = semanticTime(d.StartTimestamp, d.StartTimestampMs)
+	if err != nil {
+		return start, end, err
+	}
+	end, err = semanticTime(d.EndTimestamp, d.EndTimestampMs)
+	return start, end, err
+}
+
+// semanticLocation returns the Location at lat, lon and time t
+func semanticLocation(lat, lon, accuracy IntString, t time.Time, source string) Location {
+	return Location{
+		LatitudeE7:  lat,
+		LongitudeE7: lon,
+		Accuracy:    accuracy,
+		Timestamp:   t.UTC().Format(semanticTimeFormat),
+		Source:      source,
+	}
+}
+
+// locations returns the points of the raw path
+func (p rawPath) locations() []Location {
+	var locs []Location
+	for _, pt := range p.Points {
+		t, err := semanticTime(pt.Timestamp, pt.TimestampMs)
+		if err != nil {
+			continue
+		}
+		locs = append(locs, semanticLocation(pt.LatE7, pt.LngE7, pt.AccuracyMeters, t, sourceRawPath))
+	}
+	return locs
+}
+
+// locations returns the start and end of the visit and the points of its raw path
+func (v *placeVisit) locations() ([]Location, error) {
+	start, end, err := v.Duration.interval()
+	if err != nil {
+		return nil, err
+	}
+	locs := v.SimplifiedRawPath.locations()
+	if v.Location.LatitudeE7 != "" {
+		locs = append(locs,
+			semanticLocation(v.Location.LatitudeE7, v.Location.LongitudeE7, "", start, sourceVisit),
+			semanticLocation(v.Location.LatitudeE7, v.Location.LongitudeE7, "", end, sourceVisit))
+	}
+	return locs, nil
+}
+
+// locations returns the start and end of the activity with the points in between.
+// The points of the raw path are used if any, as they are timestamped.
+// Otherwise the waypoints are used, evenly spread between the start and the end.
+func (a *activitySegment) locations() ([]Location, error) {
+	start, end, err := a.Duration.interval()
+	if err != nil {
+		return nil, err
+	}
+	locs := a.SimplifiedRawPath.locations()
+	if len(locs) == 0 {
+		n := len(a.WaypointPath.Waypoints)
+		step := end.Sub(start) / time.Duration(n+1)
+		for i, w := range a.WaypointPath.Waypoints {
+			locs = append(locs, semanticLocation(w.LatE7, w.LngE7, "", start.Add(step*time.Duration(i+1)), sourceWaypointPath))
+		}
+	}
+	if a.StartLocation.LatitudeE7 != "" {
+		locs = append(locs, semanticLocation(a.StartLocation.LatitudeE7, a.StartLocation.LongitudeE7, "", start, sourceActivity))
+	}
+	if a.EndLocation.LatitudeE7 != "" {
+		locs = append(locs, semanticLocation(a.EndLocation.LatitudeE7, a.EndLocation.LongitudeE7, "", end, sourceActivity))
+	}
+	return locs, nil
+}
+
+// getSemanticLocations returns the locations of a timeline object sorted by time
+func getSemanticLocations(data []byte) ([]Location, error) {
+	var obj semanticObject
+	err := json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil, err
+	}
+	var locs []Location
+	switch {
+	case obj.ActivitySegment != nil:
+		locs, err = obj.ActivitySegment.locations()
+	case obj.PlaceVisit != nil:
+		locs, err = obj.PlaceVisit.locations()
+	default:
+		// not a location
+		return nil, nil
+	}
+	sort.SliceStable(locs, func(i, j int) bool { return locs[i].Timestamp < locs[j].Timestamp })
+	return locs, err
+}
@@ -0,0 +1,50 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetSemanticLocations(t *testing.T) {
+	data := []struct {
+		in  string
+		out []Location
+	}{
+		{
+			`{"placeVisit": {"location": {"latitudeE7": 506443831, "longitudeE7": 30536723}, "duration": {"startTimestamp": "2022-03-01T08:40:02Z", "endTimestamp": "2022-03-01T12:01:00.5Z"}}}`,
+			[]Location{
+				{LatitudeE7: "506443831", LongitudeE7: "30536723", Timestamp: "2022-03-01T08:40:02.000Z", Source: sourceVisit},
+				{LatitudeE7: "506443831", LongitudeE7: "30536723", Timestamp: "2022-03-01T12:01:00.500Z", Source: sourceVisit},
+			},
+		},
+		{
+			`{"activitySegment": {"startLocation": {"latitudeE7": 1, "longitudeE7": 2}, "endLocation": {"latitudeE7": 5, "longitudeE7": 6},
+			"duration": {"startTimestampMs": "1646122200000", "endTimestampMs": "1646122500000"},
+			"waypointPath": {"waypoints": [{"latE7": 3, "lngE7": 4}, {"latE7": 4, "lngE7": 5}]}}}`,
+			[]Location{
+				{LatitudeE7: "1", LongitudeE7: "2", Timestamp: "2022-03-01T08:10:00.000Z", Source: sourceActivity},
+				{LatitudeE7: "3", LongitudeE7: "4", Timestamp: "2022-03-01T08:11:40.000Z", Source: sourceWaypointPath},
+				{LatitudeE7: "4", LongitudeE7: "5", Timestamp: "2022-03-01T08:13:20.000Z", Source: sourceWaypointPath},
+				{LatitudeE7: "5", LongitudeE7: "6", Timestamp: "2022-03-01T08:15:00.000Z", Source: sourceActivity},
+			},
+		},
+		{
+			`{"activitySegment": {"startLocation": {"latitudeE7": 1, "longitudeE7": 2}, "endLocation": {"latitudeE7": 5, "longitudeE7": 6},
+			"duration": {"startTimestamp": "2022-03-01T08:10:00Z", "endTimestamp": "2022-03-01T08:15:00Z"},
+			"waypointPath": {"waypoints": [{"latE7": 3, "lngE7": 4}]},
+			"simplifiedRawPath": {"points": [{"latE7": 2, "lngE7": 3, "accuracyMeters": 12, "timestamp": "2022-03-01T08:12:00Z"}]}}}`,
+			[]Location{
+				{LatitudeE7: "1", LongitudeE7: "2", Timestamp: "2022-03-01T08:10:00.000Z", Source: sourceActivity},
+				{LatitudeE7: "2", LongitudeE7: "3", Accuracy: "12", Timestamp: "2022-03-01T08:12:00.000Z", Source: sourceRawPath},
+				{LatitudeE7: "5", LongitudeE7: "6", Timestamp: "2022-03-01T08:15:00.000Z", Source: sourceActivity},
+			},
+		},
+	}
+
+	for _, d := range data {
+		got, err := getSemanticLocations([]byte(d.in))
+		if err != nil || !reflect.DeepEqual(got, d.out) {
+			t.Errorf("getSemanticLocations(%s) = %v, %v != %v", d.in, got, err, d.out)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package history
+
+import (
+	"math"
+	"time"
+)
+
+// NewSimplifier returns a Writer that reduces the number of locations of each segment,
+// keeping its first and last locations. With an interval, the locations less than interval
+// after the previous kept one are removed. With a tolerance, the segment is simplified with
+// the Douglas-Peucker algorithm, removing the locations less than tolerance meters
+// from the simplified line. Each of them is ignored if zero.
+func NewSimplifier(w Writer, tolerance float64, interval time.Duration) *SegmentProcessor {
+	return NewSegmentProcessor(w, func(segment []Location) []Location {
+		if interval > 0 {
+			segment = downsample(segment, interval)
+		}
+		if tolerance > 0 {
+			segment = douglasPeucker(segment, tolerance)
+		}
+		return segment
+	})
+}
+
+// downsample keeps the locations at least interval after the previous kept one.
+// The locations without valid time are kept.
+func downsample(locations []Location, interval time.Duration) []Location {
+	if len(locations) <= 2 {
+		return locations
+	}
+	kept := []Location{locations[0]}
+	last, err := time.Parse(time.RFC3339, locations[0].Timestamp)
+	hasLast := err == nil
+	for _, l := range locations[1 : len(locations)-1] {
+		t, err := time.Parse(time.RFC3339, l.Timestamp)
+		if err != nil {
+			kept = append(kept, l)
+			continue
+		}
+		if !hasLast || t.Sub(last) >= interval {
+			kept = append(kept, l)
+			last, hasLast = t, true
+		}
+	}
+	return append(kept, locations[len(locations)-1])
+}
+
+// xy is a position in meters on a local plane
+type xy struct {
+	x, y float64
+}
+
+// lineDistance returns the distance from p to the line segment [a, b]
+func lineDistance(p, a, b xy) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	t := 0.0
+	if d := dx*dx + dy*dy; d > 0 {
+		t = math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/d))
+	}
+	return math.Hypot(p.x-a.x-t*dx, p.y-a.y-t*dy)
+}
+
+// douglasPeucker keeps the locations more than tolerance meters from the simplified line.
+// The locations without valid coordinates are kept.
+func douglasPeucker(locations []Location, tolerance float64) []Location {
+	if len(locations) <= 2 {
+		return locations
+	}
+	// project the valid locations on a plane tangent at the first one
+	var points []xy
+	var indexes []int
+	var lat0 float64
+	for i, l := range locations {
+		lat, lon, err := coords(l)
+		if err != nil {
+			continue
+		}
+		if len(points) == 0 {
+			lat0 = e7toRad(lat)
+		}
+		points = append(points, xy{earthRadius * e7toRad(lon) * math.Cos(lat0), earthRadius * e7toRad(lat)})
+		indexes = append(indexes, i)
+	}
+	keep := make([]bool, len(locations))
+	for i, l := range locations {
+		if _, _, err := coords(l); err != nil {
+			keep[i] = true
+		}
+	}
+	if len(points) > 0 {
+		keep[indexes[0]], keep[indexes[len(indexes)-1]] = true, true
+	}
+	// the ranges of points to simplify, without recursion for the long segments
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+		farthest, max := 0, tolerance
+		for i := first + 1; i < last; i++ {
+			if d := lineDistance(points[i], points[first], points[last]); d > max {
+				farthest, max = i, d
+			}
+		}
+		if farthest > 0 {
+			keep[indexes[farthest]] = true
+			stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+	var kept []Location
+	for i, l := range locations {
+		if keep[i] {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,75 @@
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimplifier(t *testing.T) {
+	data := []struct {
+		name      string
+		tolerance float64
+		interval  time.Duration
+		lons      []int
+		out       string
+	}{
+		// a straight line is reduced to its endpoints
+		{"line", 10, 0, []int{0, 10000, 20000, 30000, 40000}, "loc 0,loc 40000"},
+		{"interval", 0, 25 * time.Second, []int{0, 10000, 20000, 30000, 40000, 50000, 60000}, "loc 0,loc 30000,loc 60000"},
+		{"interval end", 0, 40 * time.Second, []int{0, 10000, 20000, 30000, 40000, 50000}, "loc 0,loc 40000,loc 50000"},
+		{"short", 10, 0, []int{0, 10000}, "loc 0,loc 10000"},
+	}
+
+	for _, d := range data {
+		p := &positions{}
+		s := NewSimplifier(p, d.tolerance, d.interval)
+		for _, l := range equatorTrack("", d.lons...) {
+			s.WriteLocation(l)
+		}
+		s.WriteFooter()
+		if got := locationLons(p.events); got != d.out {
+			t.Errorf("Simplifier %s wrote\n%s\nexpected\n%s", d.name, got, d.out)
+		}
+	}
+}
+
+func TestSimplifierSegments(t *testing.T) {
+	// a peak 55m from the line and a point 10cm from the way back in the first segment,
+	// and a straight line in the second one
+	locations := equatorTrack("", 0, 10000, 20000, 30000, 40000, 50000, 60000)
+	locations[1].LatitudeE7 = "5000"
+	locations[2].LatitudeE7 = "2510"
+	p := &positions{}
+	s := NewSimplifier(p, 10, 0)
+	s.WriteHeader()
+	for i, l := range locations {
+		if i == 4 {
+			s.WriteNewSegment()
+		}
+		s.WriteLocation(l)
+	}
+	s.WriteFooter()
+	expected := "header|loc 2023-07-14T08:00:00Z 0,0|loc 2023-07-14T08:00:10Z 5000,10000|loc 2023-07-14T08:00:30Z 0,30000|segment|loc 2023-07-14T08:00:40Z 0,40000|loc 2023-07-14T08:01:00Z 0,60000|footer"
+	if got := strings.Join(p.events, "|"); got != expected {
+		t.Errorf("Simplifier wrote\n%s\nexpected\n%s", got, expected)
+	}
+}
+
+func TestSimplifierWaypoints(t *testing.T) {
+	p := &positions{}
+	s := NewSimplifier(p, 10, 0)
+	s.WriteWaypoint(Waypoint{LatitudeE7: "1", LongitudeE7: "2"})
+	for _, l := range equatorTrack("", 0, 10000, 20000) {
+		s.WriteLocation(l)
+		if l.LongitudeE7 == "10000" {
+			s.WriteWaypoint(Waypoint{LatitudeE7: "3", LongitudeE7: "4"})
+		}
+	}
+	s.WriteFooter()
+	// the waypoint received in the segment is written after its locations
+	expected := "wpt 1,2  |loc 2023-07-14T08:00:00Z 0,0|loc 2023-07-14T08:00:20Z 0,20000|wpt 3,4  |footer"
+	if got := strings.Join(p.events, "|"); got != expected {
+		t.Errorf("Simplifier wrote\n%s\nexpected\n%s", got, expected)
+	}
+}
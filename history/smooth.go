@@ -0,0 +1,142 @@
+package history
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Smoothing smooths the coordinates of the locations of a segment in place
+type Smoothing func(segment []Location)
+
+// defaultAccuracy is the accuracy in meters of the locations without accuracy
+const defaultAccuracy = 50
+
+// setCoords sets the coordinates of the location from E7 values
+func setCoords(l *Location, lat, lon float64) {
+	l.LatitudeE7 = IntString(strconv.FormatInt(int64(math.Round(lat)), 10))
+	l.LongitudeE7 = IntString(strconv.FormatInt(int64(math.Round(lon)), 10))
+}
+
+// kalman returns a Kalman filter smoothing, the accuracy of the locations being the
+// measurement noise and speed (in m/s) the expected speed between the locations
+func kalman(speed float64) Smoothing {
+	return func(segment []Location) {
+		var lat, lon, variance float64
+		var last time.Time
+		started := false
+		for i := range segment {
+			mlat, mlon, err := coords(segment[i])
+			if err != nil {
+				continue
+			}
+			accuracy, err := segment[i].Accuracy.Float64()
+			if err != nil {
+				accuracy = defaultAccuracy
+			}
+			accuracy = math.Max(accuracy, 1)
+			t, err := time.Parse(time.RFC3339, segment[i].Timestamp)
+			if !started {
+				lat, lon, variance, last, started = float64(mlat), float64(mlon), accuracy*accuracy, t, true
+				continue
+			}
+			// the uncertainty grows with the time since the last location
+			if err == nil {
+				if dt := t.Sub(last).Seconds(); dt > 0 {
+					variance += dt * speed * speed
+				}
+				last = t
+			}
+			// the gain is between 0 (keep the estimate) and 1 (take the measure)
+			k := variance / (variance + accuracy*accuracy)
+			lat += k * (float64(mlat) - lat)
+			lon += k * (float64(mlon) - lon)
+			variance *= 1 - k
+			setCoords(&segment[i], lat, lon)
+		}
+	}
+}
+
+// median returns the median of the values, that are sorted in place
+func median(values []int64) float64 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	n := len(values)
+	if n%2 == 1 {
+		return float64(values[n/2])
+	}
+	return (float64(values[n/2-1]) + float64(values[n/2])) / 2
+}
+
+// movingMedian returns a moving median smoothing over window locations,
+// the window being shorter near the ends so that the first and last locations are kept
+func movingMedian(window int) Smoothing {
+	return func(segment []Location) {
+		// the coordinates before the smoothing
+		lats, lons := make([]int64, len(segment)), make([]int64, len(segment))
+		valid := make([]bool, len(segment))
+		for i, l := range segment {
+			var err error
+			lats[i], lons[i], err = coords(l)
+			valid[i] = err == nil
+		}
+		for i := range segment {
+			if !valid[i] {
+				continue
+			}
+			half := window / 2
+			if i < half {
+				half = i
+			}
+			if len(segment)-1-i < half {
+				half = len(segment) - 1 - i
+			}
+			var wlats, wlons []int64
+			for j := i - half; j <= i+half; j++ {
+				if valid[j] {
+					wlats, wlons = append(wlats, lats[j]), append(wlons, lons[j])
+				}
+			}
+			setCoords(&segment[i], median(wlats), median(wlons))
+		}
+	}
+}
+
+// ParseSmoothing parses the smoothing method
+//   - kalman or kalman:<speed> : Kalman filter with the expected speed in m/s [default: 3]
+//   - median or median:<n> : moving median over n locations, n being odd [default: 5]
+func ParseSmoothing(s string) (Smoothing, error) {
+	method, param, hasParam := strings.Cut(strings.TrimSpace(s), ":")
+	switch method {
+	case "kalman":
+		speed := 3.0
+		if hasParam {
+			var err error
+			if speed, err = strconv.ParseFloat(param, 64); err != nil || speed <= 0 {
+				return nil, fmt.Errorf("invalid kalman speed %q, expected a positive number of m/s", param)
+			}
+		}
+		return kalman(speed), nil
+	case "median":
+		window := 5
+		if hasParam {
+			var err error
+			if window, err = strconv.Atoi(param); err != nil || window < 3 || window%2 == 0 {
+				return nil, fmt.Errorf("invalid median window %q, expected an odd number of locations from 3", param)
+			}
+		}
+		return movingMedian(window), nil
+	}
+	return nil, fmt.Errorf("unknown smoothing %q, expected kalman or median", s)
+}
+
+// NewSmoother returns a Writer that smooths the coordinates of the locations of each segment,
+// their accuracy being kept
+func NewSmoother(w Writer, smooth Smoothing) *SegmentProcessor {
+	return NewSegmentProcessor(w, func(segment []Location) []Location {
+		smooth(segment)
+		return segment
+	})
+}
@@ -0,0 +1,107 @@
+package history
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMovingMedian(t *testing.T) {
+	data := []struct {
+		smoothing string
+		lons      []int
+		out       string
+	}{
+		{"median:3", []int{0, 10000, 20000, 900000, 40000, 50000, 60000}, "loc 0,loc 10000,loc 20000,loc 40000,loc 50000,loc 50000,loc 60000"},
+		{"median", []int{0, 10000, 900000, 30000, 40000, 50000}, "loc 0,loc 10000,loc 30000,loc 40000,loc 40000,loc 50000"},
+		{"median", []int{0, 10000}, "loc 0,loc 10000"},
+	}
+
+	for _, d := range data {
+		smoothing, err := ParseSmoothing(d.smoothing)
+		if err != nil {
+			t.Errorf("ParseSmoothing(%s) error: %v", d.smoothing, err)
+			continue
+		}
+		p := &positions{}
+		s := NewSmoother(p, smoothing)
+		for _, l := range equatorTrack("", d.lons...) {
+			s.WriteLocation(l)
+		}
+		s.WriteFooter()
+		if got := locationLons(p.events); got != d.out {
+			t.Errorf("Smoother %s of %v wrote\n%s\nexpected\n%s", d.smoothing, d.lons, got, d.out)
+		}
+	}
+}
+
+func TestKalman(t *testing.T) {
+	locations := equatorTrack("", 0, 10000, 20000, 30000, 40000)
+	for i, accuracy := range []IntString{"10", "1000", "1", "1000", ""} {
+		locations[i].Accuracy = accuracy
+	}
+	// the inaccurate location hardly moves the estimate, the accurate one is followed,
+	// and a new segment starts from its first location
+	expected := "header|loc 0/10|loc 2/1000|loc 19929/1|segment|loc 30000/1000|loc 39975/|footer"
+
+	smoothing, _ := ParseSmoothing("kalman")
+	a := &accuracies{}
+	s := NewSmoother(a, smoothing)
+	s.WriteHeader()
+	for i, l := range locations {
+		if i == 3 {
+			s.WriteNewSegment()
+		}
+		s.WriteLocation(l)
+	}
+	s.WriteFooter()
+	if got := strings.Join(a.events, "|"); got != expected {
+		t.Errorf("Smoother kalman wrote\n%s\nexpected\n%s", got, expected)
+	}
+}
+
+// accuracies is a recorder of the longitude and the accuracy of the locations
+type accuracies struct {
+	recorder
+}
+
+func (a *accuracies) WriteLocation(l Location) error {
+	a.events = append(a.events, "loc "+string(l.LongitudeE7)+"/"+string(l.Accuracy))
+	return nil
+}
+
+func TestParseSmoothing(t *testing.T) {
+	for _, s := range []string{"kalman", "kalman:1.5", "median", "median:7"} {
+		if _, err := ParseSmoothing(s); err != nil {
+			t.Errorf("ParseSmoothing(%s) error: %v", s, err)
+		}
+	}
+	for _, s := range []string{"mean", "kalman:0", "kalman:x", "median:4", "median:1"} {
+		if _, err := ParseSmoothing(s); err == nil {
+			t.Errorf("ParseSmoothing(%s) should fail", s)
+		}
+	}
+}
+
+func TestSmootherAfterPrivacy(t *testing.T) {
+	// a walk on the equator to a zone around the last two locations
+	zones, _ := ParseZones("0,0.01,150")
+	smoothing, _ := ParseSmoothing("kalman:0.5")
+	p := &positions{}
+	// the chain of main, the zones being hidden before the smoothing
+	w := NewPrivacyFilter(NewSmoother(p, smoothing), zones, false, 0)
+	w.WriteHeader()
+	for _, l := range equatorTrack("", 0, 10000, 20000, 30000, 40000, 50000, 60000, 70000, 80000, 90000, 100000) {
+		w.WriteLocation(l)
+	}
+	w.WriteFooter()
+	// the smoothed locations lag behind, but the ones in the zone and their time are not written
+	var times []string
+	for _, e := range p.events {
+		if strings.HasPrefix(e, "loc ") {
+			times = append(times, strings.Fields(e)[1])
+		}
+	}
+	if len(times) != 9 || times[8] != "2023-07-14T08:01:20Z" {
+		t.Errorf("Smoother after PrivacyFilter wrote\n%s", strings.Join(p.events, "|"))
+	}
+}
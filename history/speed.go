@@ -0,0 +1,203 @@
+package history
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxSpeeds are the maximal speeds in km/h of the activities,
+// the "" key being the maximal speed of the locations without activity
+var DefaultMaxSpeeds = map[string]float64{
+	"":              300,
+	ActivityStill:   30,
+	ActivityWalking: 30,
+	ActivityRunning: 40,
+	ActivityCycling: 80,
+	ActivityVehicle: 300,
+}
+
+// lookAhead is the number of following locations checked before removing a spike
+const lookAhead = 5
+
+// ParseMaxSpeeds parses comma separated maximal speeds in km/h, each being
+// a speed for the locations without activity (300), or the speed of an activity (cycling:60).
+// The speeds that are not set keep their default value.
+func ParseMaxSpeeds(s string) (map[string]float64, error) {
+	speeds := map[string]float64{}
+	for k, v := range DefaultMaxSpeeds {
+		speeds[k] = v
+	}
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		activity, speed := "", e
+		if i := strings.Index(e, ":"); i >= 0 {
+			activities, err := ParseActivities(e[:i])
+			if err != nil {
+				return nil, err
+			}
+			activity, speed = activities[0], e[i+1:]
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(speed), 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid speed %q, expected a positive number of km/h", e)
+		}
+		speeds[activity] = v
+	}
+	return speeds, nil
+}
+
+// SpeedFilter is a Writer that removes the spikes, i.e. the locations that are reached
+// from the previous one faster than the maximal speed of their activities.
+// A location is only removed if one of the next lookAhead locations can be reached
+// from the previous one, otherwise it is kept as the start of a true jump (a flight...).
+// The first location is removed if the next ones agree with each other but can't be reached from it.
+type SpeedFilter struct {
+	w Writer
+	// the maximal speeds in m/s by activity
+	speeds map[string]float64
+	// the locations waiting for the look ahead
+	pending []Location
+	// the last written location
+	last    Location
+	hasLast bool
+	// Removed is the number of removed locations
+	Removed int
+}
+
+// NewSpeedFilter returns a SpeedFilter writing to w, with the maximal speeds in km/h by activity
+func NewSpeedFilter(w Writer, speeds map[string]float64) *SpeedFilter {
+	f := &SpeedFilter{w: w, speeds: map[string]float64{}}
+	for activity, speed := range speeds {
+		f.speeds[activity] = speed / 3.6
+	}
+	return f
+}
+
+// maxSpeed returns the maximal speed in m/s of the activity
+func (f *SpeedFilter) maxSpeed(activity string) float64 {
+	if speed, ok := f.speeds[activity]; ok {
+		return speed
+	}
+	return f.speeds[""]
+}
+
+// reachable returns true if b can be reached from a without exceeding the maximal speed
+// of their activities. The locations without position or time are always reachable.
+func (f *SpeedFilter) reachable(a, b Location) bool {
+	alat, alon, err := coords(a)
+	if err != nil {
+		return true
+	}
+	blat, blon, err := coords(b)
+	if err != nil {
+		return true
+	}
+	ta, err := time.Parse(time.RFC3339, a.Timestamp)
+	if err != nil {
+		return true
+	}
+	tb, err := time.Parse(time.RFC3339, b.Timestamp)
+	if err != nil {
+		return true
+	}
+	// the locations of the same second are one second apart
+	seconds := tb.Sub(ta).Seconds()
+	if seconds < 1 {
+		seconds = 1
+	}
+	max := f.maxSpeed(a.Activity)
+	if s := f.maxSpeed(b.Activity); s > max {
+		max = s
+	}
+	return distance(alat, alon, blat, blon)/seconds <= max
+}
+
+// next writes or removes the first pending location
+func (f *SpeedFilter) next() error {
+	l := f.pending[0]
+	f.pending = f.pending[1:]
+	if f.hasLast && !f.reachable(f.last, l) {
+		for _, n := range f.pending {
+			if f.reachable(f.last, n) {
+				// l is a spike
+				f.Removed++
+				return nil
+			}
+		}
+	}
+	if !f.hasLast && f.firstSpike(l) {
+		f.Removed++
+		return nil
+	}
+	if _, _, err := coords(l); err == nil {
+		f.last, f.hasLast = l, true
+	}
+	return f.w.WriteLocation(l)
+}
+
+// firstSpike returns true if the first location l is a spike, i.e. if the next locations
+// agree with each other but none of them can be reached from l
+func (f *SpeedFilter) firstSpike(l Location) bool {
+	if len(f.pending) < 2 || !f.reachable(f.pending[0], f.pending[1]) {
+		return false
+	}
+	for _, n := range f.pending {
+		if f.reachable(l, n) {
+			return false
+		}
+	}
+	return true
+}
+
+// flush writes or removes all the pending locations
+func (f *SpeedFilter) flush() error {
+	for len(f.pending) > 0 {
+		if err := f.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *SpeedFilter) WriteHeader() error {
+	return f.w.WriteHeader()
+}
+
+func (f *SpeedFilter) WriteLocation(l Location) error {
+	f.pending = append(f.pending, l)
+	if len(f.pending) <= lookAhead {
+		return nil
+	}
+	return f.next()
+}
+
+func (f *SpeedFilter) WriteNewSegment() error {
+	if err := f.flush(); err != nil {
+		return err
+	}
+	return f.w.WriteNewSegment()
+}
+
+func (f *SpeedFilter) WriteNewTrack() error {
+	if err := f.flush(); err != nil {
+		return err
+	}
+	return f.w.WriteNewTrack()
+}
+
+func (f *SpeedFilter) WriteWaypoint(w Waypoint) error {
+	return f.w.WriteWaypoint(w)
+}
+
+func (f *SpeedFilter) WriteFooter() error {
+	if err := f.flush(); err != nil {
+		return err
+	}
+	return f.w.WriteFooter()
+}
+
+func (f *SpeedFilter) Flush() error {
+	return f.w.Flush()
+}
@@ -0,0 +1,102 @@
+package history
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// equatorTrack returns locations every 10 seconds on the equator, at the longitudes in E7 format
+func equatorTrack(activity string, lons ...int) []Location {
+	var locations []Location
+	for i, lon := range lons {
+		locations = append(locations, Location{
+			LatitudeE7:  "0",
+			LongitudeE7: IntString(strconv.Itoa(lon)),
+			Timestamp:   fmt.Sprintf("2023-07-14T08:%02d:%02dZ", i/6, i%6*10),
+			Activity:    activity,
+		})
+	}
+	return locations
+}
+
+// locationLons returns the longitudes of the written locations
+func locationLons(events []string) string {
+	var lons []string
+	for _, e := range events {
+		if strings.HasPrefix(e, "loc ") {
+			lons = append(lons, "loc "+e[strings.Index(e, ",")+1:])
+		}
+	}
+	return strings.Join(lons, ",")
+}
+
+func TestSpeedFilter(t *testing.T) {
+	data := []struct {
+		name      string
+		speeds    string
+		locations []Location
+		out       string
+	}{
+		// 111m every 10 seconds is 40 km/h, the spike is 11km away
+		{"spike", "300", equatorTrack("", 0, 10000, 20000, 1000000, 40000, 50000, 60000, 70000),
+			"loc 0,loc 10000,loc 20000,loc 40000,loc 50000,loc 60000,loc 70000"},
+		// two consecutive spikes
+		{"spikes", "300", equatorTrack("", 0, 10000, 1000000, 1010000, 40000, 50000, 60000, 70000),
+			"loc 0,loc 10000,loc 40000,loc 50000,loc 60000,loc 70000"},
+		// a spike at the start
+		{"first spike", "300", equatorTrack("", 1000000, 0, 10000, 20000, 30000, 40000, 50000),
+			"loc 0,loc 10000,loc 20000,loc 30000,loc 40000,loc 50000"},
+		// a jump without return is kept
+		{"jump", "300", equatorTrack("", 0, 10000, 20000, 1000000, 1010000, 1020000, 1030000, 1040000, 1050000, 1060000),
+			"loc 0,loc 10000,loc 20000,loc 1000000,loc 1010000,loc 1020000,loc 1030000,loc 1040000,loc 1050000,loc 1060000"},
+		// 200m in 10 seconds is 72 km/h, too fast for walking only
+		{"walking", "", equatorTrack(ActivityWalking, 0, 1260, 2520, 20000, 5040, 6300),
+			"loc 0,loc 1260,loc 2520,loc 5040,loc 6300"},
+		{"walking faster", "walking:100", equatorTrack(ActivityWalking, 0, 1260, 2520, 20000, 5040, 6300),
+			"loc 0,loc 1260,loc 2520,loc 20000,loc 5040,loc 6300"},
+	}
+
+	for _, d := range data {
+		speeds := DefaultMaxSpeeds
+		if d.speeds != "" {
+			var err error
+			if speeds, err = ParseMaxSpeeds(d.speeds); err != nil {
+				t.Errorf("ParseMaxSpeeds(%s) error: %v", d.speeds, err)
+				continue
+			}
+		}
+		p := &positions{}
+		f := NewSpeedFilter(p, speeds)
+		for _, l := range d.locations {
+			f.WriteLocation(l)
+		}
+		f.WriteFooter()
+		got := locationLons(p.events)
+		if got != d.out {
+			t.Errorf("SpeedFilter %s wrote\n%s\nexpected\n%s", d.name, got, d.out)
+		}
+		if f.Removed != len(d.locations)-strings.Count(got, "loc ") {
+			t.Errorf("SpeedFilter %s removed %d locations", d.name, f.Removed)
+		}
+	}
+}
+
+func TestParseMaxSpeeds(t *testing.T) {
+	speeds, err := ParseMaxSpeeds("200, cycling:60")
+	if err != nil {
+		t.Fatalf("ParseMaxSpeeds error: %v", err)
+	}
+	if speeds[""] != 200 || speeds[ActivityCycling] != 60 || speeds[ActivityWalking] != DefaultMaxSpeeds[ActivityWalking] {
+		t.Errorf("ParseMaxSpeeds = %v", speeds)
+	}
+	if DefaultMaxSpeeds[""] != 300 {
+		t.Errorf("ParseMaxSpeeds changed the defaults")
+	}
+	for _, s := range []string{"fast", "-10", "flying:100", "cycling:"} {
+		if _, err := ParseMaxSpeeds(s); err == nil {
+			t.Errorf("ParseMaxSpeeds(%s) should fail", s)
+		}
+	}
+}
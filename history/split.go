@@ -0,0 +1,147 @@
+package history
+
+import (
+	"fmt"
+	"time"
+)
+
+// Period returns the name of the period of a location, for example "2023-07-14" for a day,
+// or "" if the location has no valid timestamp
+type Period func(l Location) string
+
+// ParsePeriod returns the Period "day" (2023-07-14), "week" (2023-W28, ISO week)
+// or "month" (2023-07), the dates being taken in the time zone tz
+func ParsePeriod(s string, tz *time.Location) (Period, error) {
+	var name func(t time.Time) string
+	switch s {
+	case "day":
+		name = func(t time.Time) string { return t.Format("2006-01-02") }
+	case "week":
+		name = func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}
+	case "month":
+		name = func(t time.Time) string { return t.Format("2006-01") }
+	default:
+		return nil, fmt.Errorf("unknown period %q, expected day, week or month", s)
+	}
+	return func(l Location) string {
+		t, err := time.Parse(time.RFC3339, l.Timestamp)
+		if err != nil {
+			return ""
+		}
+		return name(t.In(tz))
+	}, nil
+}
+
+// Splitter is a Writer that writes each period of the locations to its own Writer.
+// The Writer of a period is obtained from open when its first location comes,
+// so the empty periods are skipped. At the end of the period its footer is written,
+// it is flushed and close is called.
+// The new tracks and segments at the boundaries are dropped.
+type Splitter struct {
+	period Period
+	open   func(period string) (Writer, error)
+	close  func() error
+	// w is the Writer of the current period, nil if none is open
+	w       Writer
+	current string
+	// the new track or segment to write before the next location
+	newTrack, newSegment bool
+}
+
+// NewSplitter returns a Splitter of the periods, whose writers are opened and closed
+// with open and close
+func NewSplitter(period Period, open func(period string) (Writer, error), close func() error) *Splitter {
+	return &Splitter{period: period, open: open, close: close}
+}
+
+// endPeriod writes the footer of the current period and closes it
+func (s *Splitter) endPeriod() error {
+	w := s.w
+	s.w, s.newTrack, s.newSegment = nil, false, false
+	if err := w.WriteFooter(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return s.close()
+}
+
+// startPeriod opens the period (if it is not the current one) and writes its header.
+// A location without period stays in the current one.
+func (s *Splitter) startPeriod(period string) error {
+	if s.w != nil && (period == s.current || period == "") {
+		return nil
+	}
+	if s.w != nil {
+		if err := s.endPeriod(); err != nil {
+			return err
+		}
+	}
+	w, err := s.open(period)
+	if err != nil {
+		return err
+	}
+	s.w, s.current = w, period
+	return w.WriteHeader()
+}
+
+func (s *Splitter) WriteHeader() error {
+	// the headers are written when the periods start
+	return nil
+}
+
+func (s *Splitter) WriteLocation(l Location) error {
+	if err := s.startPeriod(s.period(l)); err != nil {
+		return err
+	}
+	var err error
+	if s.newTrack {
+		err = s.w.WriteNewTrack()
+	} else if s.newSegment {
+		err = s.w.WriteNewSegment()
+	}
+	s.newTrack, s.newSegment = false, false
+	if err != nil {
+		return err
+	}
+	return s.w.WriteLocation(l)
+}
+
+func (s *Splitter) WriteNewSegment() error {
+	s.newSegment = true
+	return nil
+}
+
+func (s *Splitter) WriteNewTrack() error {
+	s.newTrack = true
+	return nil
+}
+
+// WriteWaypoint writes the waypoint in the current period, as the stays are written
+// when we leave them, or in the period of its arrival if none is open
+func (s *Splitter) WriteWaypoint(w Waypoint) error {
+	if s.w == nil {
+		if err := s.startPeriod(s.period(Location{Timestamp: w.Arrival})); err != nil {
+			return err
+		}
+	}
+	return s.w.WriteWaypoint(w)
+}
+
+func (s *Splitter) WriteFooter() error {
+	if s.w == nil {
+		return nil
+	}
+	return s.endPeriod()
+}
+
+func (s *Splitter) Flush() error {
+	if s.w == nil {
+		return nil
+	}
+	return s.w.Flush()
+}
@@ -0,0 +1,112 @@
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	data := []struct {
+		period string
+		tz     *time.Location
+		ts     string
+		out    string
+	}{
+		{"day", time.UTC, "2023-07-14T20:00:00Z", "2023-07-14"},
+		{"day", tokyo, "2023-07-14T20:00:00Z", "2023-07-15"},
+		{"week", time.UTC, "2023-07-14T20:00:00Z", "2023-W28"},
+		{"week", time.UTC, "2021-01-01T00:00:00Z", "2020-W53"},
+		{"month", time.UTC, "2023-07-31T20:00:00Z", "2023-07"},
+		{"month", tokyo, "2023-07-31T20:00:00Z", "2023-08"},
+		{"day", time.UTC, "invalid", ""},
+	}
+
+	for _, d := range data {
+		period, err := ParsePeriod(d.period, d.tz)
+		if err != nil {
+			t.Errorf("ParsePeriod(%s) error: %v", d.period, err)
+			continue
+		}
+		if got := period(Location{Timestamp: d.ts}); got != d.out {
+			t.Errorf("ParsePeriod(%s) of %s in %s = %s != %s", d.period, d.ts, d.tz, got, d.out)
+		}
+	}
+
+	if _, err := ParsePeriod("year", time.UTC); err == nil {
+		t.Errorf("ParsePeriod(year) should fail")
+	}
+}
+
+func TestSplitter(t *testing.T) {
+	locations := []Location{
+		{Timestamp: "2023-07-14T08:00:00Z"},
+		{Timestamp: "2023-07-14T09:00:00Z"},
+		{Timestamp: "2023-07-16T08:00:00Z"},
+		{Timestamp: "2023-07-16T09:00:00Z"},
+	}
+	expected := []string{
+		"open 2023-07-14",
+		"header",
+		"loc 2023-07-14T08:00:00Z",
+		"segment",
+		"loc 2023-07-14T09:00:00Z",
+		"footer",
+		"close",
+		"open 2023-07-16",
+		"header",
+		"loc 2023-07-16T08:00:00Z",
+		"track",
+		"loc 2023-07-16T09:00:00Z",
+		"footer",
+		"close",
+	}
+
+	r := &recorder{}
+	day, _ := ParsePeriod("day", time.UTC)
+	open := func(period string) (Writer, error) {
+		r.events = append(r.events, "open "+period)
+		return r, nil
+	}
+	close := func() error {
+		r.events = append(r.events, "close")
+		return nil
+	}
+	s := NewSplitter(day, open, close)
+	s.WriteHeader()
+	for i, l := range locations {
+		switch i {
+		case 1:
+			s.WriteNewSegment()
+		case 2, 3:
+			// the track at the boundary is dropped
+			s.WriteNewTrack()
+		}
+		s.WriteLocation(l)
+	}
+	s.WriteFooter()
+	s.Flush()
+	if strings.Join(r.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Splitter wrote\n%s\nexpected\n%s", strings.Join(r.events, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestSplitterWaypoint(t *testing.T) {
+	r := &recorder{}
+	day, _ := ParsePeriod("day", time.UTC)
+	open := func(period string) (Writer, error) {
+		r.events = append(r.events, "open "+period)
+		return r, nil
+	}
+	s := NewSplitter(day, open, func() error { return nil })
+	// a stay from the evening to the next morning is written in the morning file
+	s.WriteWaypoint(Waypoint{LatitudeE7: "1", LongitudeE7: "2", Arrival: "2023-07-13T20:00:00Z"})
+	s.WriteLocation(Location{Timestamp: "2023-07-14T08:00:00Z"})
+	s.WriteWaypoint(Waypoint{LatitudeE7: "1", LongitudeE7: "2", Arrival: "2023-07-13T22:00:00Z"})
+	s.WriteFooter()
+	expected := "open 2023-07-13|header|wpt 1,2 2023-07-13T20:00:00Z |footer|open 2023-07-14|header|loc 2023-07-14T08:00:00Z|wpt 1,2 2023-07-13T22:00:00Z |footer"
+	if got := strings.Join(r.events, "|"); got != expected {
+		t.Errorf("Splitter wrote\n%s\nexpected\n%s", got, expected)
+	}
+}
@@ -0,0 +1,97 @@
+package history
+
+import (
+	"strconv"
+	"time"
+)
+
+// StayDetector is a Writer that detects the places where we stayed,
+// i.e. where all the locations are less than radius meters from the arrival location
+// during at least duration, and writes them as waypoints to the underlying Writer
+// when we leave them. The locations are written as they come, the formats that need
+// the waypoints before the tracks (like GPX) reorder them.
+type StayDetector struct {
+	w        Writer
+	radius   float64
+	duration time.Duration
+	// the current stay candidate
+	n                  int
+	lat, lon           int64
+	sumLat, sumLon     int64
+	arrival, departure string
+}
+
+// NewStayDetector returns a StayDetector writing to w
+func NewStayDetector(w Writer, radius float64, duration time.Duration) *StayDetector {
+	return &StayDetector{w: w, radius: radius, duration: duration}
+}
+
+// closeStay writes the current candidate as a waypoint if it lasted long enough
+func (s *StayDetector) closeStay() error {
+	if s.n == 0 {
+		return nil
+	}
+	n := int64(s.n)
+	s.n = 0
+	arrival, err1 := time.Parse(time.RFC3339, s.arrival)
+	departure, err2 := time.Parse(time.RFC3339, s.departure)
+	if err1 != nil || err2 != nil || departure.Sub(arrival) < s.duration {
+		return nil
+	}
+	return s.w.WriteWaypoint(Waypoint{
+		LatitudeE7:  IntString(strconv.FormatInt(s.sumLat/n, 10)),
+		LongitudeE7: IntString(strconv.FormatInt(s.sumLon/n, 10)),
+		Arrival:     s.arrival,
+		Departure:   s.departure,
+	})
+}
+
+func (s *StayDetector) WriteHeader() error {
+	return s.w.WriteHeader()
+}
+
+func (s *StayDetector) WriteLocation(l Location) error {
+	lat, lon, err := coords(l)
+	if err != nil {
+		// not usable for the stays
+		return s.w.WriteLocation(l)
+	}
+	if s.n > 0 && distance(s.lat, s.lon, lat, lon) <= s.radius {
+		// still in the same place
+		s.n++
+		s.sumLat += lat
+		s.sumLon += lon
+		s.departure = l.Timestamp
+		return s.w.WriteLocation(l)
+	}
+	// we left the place, so this location is the new candidate
+	if err := s.closeStay(); err != nil {
+		return err
+	}
+	s.n, s.lat, s.lon, s.sumLat, s.sumLon = 1, lat, lon, lat, lon
+	s.arrival, s.departure = l.Timestamp, l.Timestamp
+	return s.w.WriteLocation(l)
+}
+
+func (s *StayDetector) WriteNewSegment() error {
+	return s.w.WriteNewSegment()
+}
+
+func (s *StayDetector) WriteNewTrack() error {
+	return s.w.WriteNewTrack()
+}
+
+func (s *StayDetector) WriteWaypoint(w Waypoint) error {
+	return s.w.WriteWaypoint(w)
+}
+
+func (s *StayDetector) WriteFooter() error {
+	if err := s.closeStay(); err != nil {
+		return err
+	}
+	return s.w.WriteFooter()
+}
+
+func (s *StayDetector) Flush() error {
+	return s.w.Flush()
+}
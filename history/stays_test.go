@@ -0,0 +1,64 @@
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder is a Writer that records what is written
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) WriteHeader() error { r.events = append(r.events, "header"); return nil }
+func (r *recorder) WriteLocation(l Location) error {
+	r.events = append(r.events, "loc "+l.Timestamp)
+	return nil
+}
+func (r *recorder) WriteNewSegment() error { r.events = append(r.events, "segment"); return nil }
+func (r *recorder) WriteNewTrack() error   { r.events = append(r.events, "track"); return nil }
+func (r *recorder) WriteWaypoint(w Waypoint) error {
+	r.events = append(r.events, "wpt "+string(w.LatitudeE7)+","+string(w.LongitudeE7)+" "+w.Arrival+" "+w.Departure)
+	return nil
+}
+func (r *recorder) WriteFooter() error { r.events = append(r.events, "footer"); return nil }
+func (r *recorder) Flush() error       { return nil }
+
+func TestStayDetector(t *testing.T) {
+	locations := []Location{
+		{LatitudeE7: "500000000", LongitudeE7: "30000000", Timestamp: "2023-07-14T08:00:00Z"},
+		{LatitudeE7: "500000100", LongitudeE7: "30000100", Timestamp: "2023-07-14T08:10:00Z"},
+		{LatitudeE7: "500000200", LongitudeE7: "30000200", Timestamp: "2023-07-14T08:20:00Z"},
+		{LatitudeE7: "500100000", LongitudeE7: "30000000", Timestamp: "2023-07-14T08:30:00Z"},
+		{LatitudeE7: "500200000", LongitudeE7: "30000000", Timestamp: "2023-07-14T08:40:00Z"},
+		{LatitudeE7: "500200100", LongitudeE7: "30000000", Timestamp: "2023-07-14T08:45:00Z"},
+	}
+	expected := []string{
+		"header",
+		"loc 2023-07-14T08:00:00Z",
+		"loc 2023-07-14T08:10:00Z",
+		"loc 2023-07-14T08:20:00Z",
+		"track",
+		// the stay is written when we leave it
+		"wpt 500000100,30000100 2023-07-14T08:00:00Z 2023-07-14T08:20:00Z",
+		"loc 2023-07-14T08:30:00Z",
+		"loc 2023-07-14T08:40:00Z",
+		"loc 2023-07-14T08:45:00Z",
+		"footer",
+	}
+
+	r := &recorder{}
+	s := NewStayDetector(r, 100, 15*time.Minute)
+	s.WriteHeader()
+	for i, l := range locations {
+		if i == 3 {
+			s.WriteNewTrack()
+		}
+		s.WriteLocation(l)
+	}
+	s.WriteFooter()
+	if strings.Join(r.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("StayDetector wrote\n%s\nexpected\n%s", strings.Join(r.events, "\n"), strings.Join(expected, "\n"))
+	}
+}
@@ -0,0 +1,232 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Besides the rawSignals, the export from the phone contains semanticSegments
+// with the following format:
+//
+//	{
+//	  "semanticSegments": [
+//	    {
+//	      "startTime": "2024-12-07T17:00:00.000+01:00",
+//	      "endTime": "2024-12-07T19:00:00.000+01:00",
+//	      "timelinePath": [
+//	        { "point": "50.6443831°, 3.0536723°", "time": "2024-12-07T17:02:00.000+01:00" },
+//	        ...
+//	      ]
+//	    },
+//	    {
+//	      "startTime": "2024-12-07T19:00:00.000+01:00",
+//	      "endTime": "2024-12-07T21:00:00.000+01:00",
+//	      "visit": { "topCandidate": { "placeLocation": { "latLng": "50.6443831°, 3.0536723°" }, ... }, ... }
+//	    },
+//	    {
+//	      "startTime": "2024-12-07T21:00:00.000+01:00",
+//	      "endTime": "2024-12-07T21:30:00.000+01:00",
+//	      "activity": { "start": { "latLng": "50.6443831°, 3.0536723°" }, "end": { "latLng": "50.6553765°, 3.0632229°" }, ... }
+//	    },
+//	    ...
+//	  ],
+//	  ...
+//	}
+
+// The sources of the locations obtained from the semantic segments
+const (
+	sourceTimelinePath = "TIMELINE_PATH"
+	sourceVisit        = "VISIT"
+	sourceActivity     = "ACTIVITY"
+)
+
+type segment struct {
+	StartTime    string `json:"startTime"`
+	EndTime      string `json:"endTime"`
+	TimelinePath []struct {
+		Point latlng `json:"point"`
+		Time  string `json:"time"`
+	} `json:"timelinePath"`
+	Visit *struct {
+		TopCandidate struct {
+			PlaceLocation struct {
+				LatLng *latlng `json:"latLng"`
+			} `json:"placeLocation"`
+		} `json:"topCandidate"`
+	} `json:"visit"`
+	Activity *struct {
+		Start struct {
+			LatLng *latlng `json:"latLng"`
+		} `json:"start"`
+		End struct {
+			LatLng *latlng `json:"latLng"`
+		} `json:"end"`
+	} `json:"activity"`
+}
+
+// segmentLocation returns the Location at ll, with the timestamp and the source
+func segmentLocation(ll latlng, timestamp, source string) Location {
+	return Location{
+		LatitudeE7:  ll.Latitude,
+		LongitudeE7: ll.Longitude,
+		Timestamp:   toUTC(timestamp),
+		Source:      source,
+		Offset:      timeOffset(timestamp),
+	}
+}
+
+// locations returns the points of the timeline path, the start and end of the visit
+// or the start and end of the activity
+func (s *segment) locations() []Location {
+	var locs []Location
+	for _, p := range s.TimelinePath {
+		locs = append(locs, segmentLocation(p.Point, p.Time, sourceTimelinePath))
+	}
+	if s.Visit != nil && s.Visit.TopCandidate.PlaceLocation.LatLng != nil {
+		ll := *s.Visit.TopCandidate.PlaceLocation.LatLng
+		locs = append(locs,
+			segmentLocation(ll, s.StartTime, sourceVisit),
+			segmentLocation(ll, s.EndTime, sourceVisit))
+	}
+	if s.Activity != nil {
+		if ll := s.Activity.Start.LatLng; ll != nil {
+			locs = append(locs, segmentLocation(*ll, s.StartTime, sourceActivity))
+		}
+		if ll := s.Activity.End.LatLng; ll != nil {
+			locs = append(locs, segmentLocation(*ll, s.EndTime, sourceActivity))
+		}
+	}
+	return locs
+}
+
+// getSegmentLocations returns the locations of a semantic segment sorted by time
+func getSegmentLocations(data []byte) ([]Location, error) {
+	var s segment
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, err
+	}
+	locs := s.locations()
+	sort.SliceStable(locs, func(i, j int) bool { return locs[i].Timestamp < locs[j].Timestamp })
+	return locs, nil
+}
+
+// The export from the iOS Google Maps app is an array of timeline entries
+// similar to the semantic segments, but with "geo:lat,lng" points:
+//
+//	[
+//	  {
+//	    "startTime": "2024-12-07T17:00:00.000+01:00",
+//	    "endTime": "2024-12-07T19:00:00.000+01:00",
+//	    "timelinePath": [
+//	      { "point": "geo:50.644383,3.053672", "durationMinutesOffsetFromStartTime": "12" },
+//	      ...
+//	    ]
+//	  },
+//	  {
+//	    "startTime": "2024-12-07T19:00:00.000+01:00",
+//	    "endTime": "2024-12-07T21:00:00.000+01:00",
+//	    "visit": { "topCandidate": { "placeLocation": "geo:50.644383,3.053672", ... }, ... }
+//	  },
+//	  {
+//	    "startTime": "2024-12-07T21:00:00.000+01:00",
+//	    "endTime": "2024-12-07T21:30:00.000+01:00",
+//	    "activity": { "start": "geo:50.644383,3.053672", "end": "geo:50.655376,3.063222", ... }
+//	  },
+//	  ...
+//	]
+
+// geoPoint is a latlng unmarshalled from "geo:XX.XXXXXX,YY.YYYYYY"
+type geoPoint latlng
+
+// Json unmashalling for geoPoint
+func (g *geoPoint) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	lat, lon, found := strings.Cut(strings.TrimPrefix(s, "geo:"), ",")
+	if !found {
+		return fmt.Errorf("invalid geo point %s", s)
+	}
+	g.Latitude, err = coordToIntString(lat)
+	if err != nil {
+		return err
+	}
+	g.Longitude, err = coordToIntString(lon)
+	return err
+}
+
+type timelineEntry struct {
+	StartTime    string `json:"startTime"`
+	EndTime      string `json:"endTime"`
+	TimelinePath []struct {
+		Point  *geoPoint `json:"point"`
+		Offset string    `json:"durationMinutesOffsetFromStartTime"`
+	} `json:"timelinePath"`
+	Visit *struct {
+		TopCandidate struct {
+			PlaceLocation *geoPoint `json:"placeLocation"`
+		} `json:"topCandidate"`
+	} `json:"visit"`
+	Activity *struct {
+		Start *geoPoint `json:"start"`
+		End   *geoPoint `json:"end"`
+	} `json:"activity"`
+}
+
+// locations returns the points of the timeline path, the start and end of the visit
+// or the start and end of the activity
+func (e *timelineEntry) locations() ([]Location, error) {
+	var locs []Location
+	if len(e.TimelinePath) > 0 {
+		start, err := time.Parse(time.RFC3339, e.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range e.TimelinePath {
+			minutes, err := strconv.ParseFloat(p.Offset, 64)
+			if err != nil || p.Point == nil {
+				continue
+			}
+			t := start.Add(time.Duration(minutes * float64(time.Minute)))
+			locs = append(locs, segmentLocation(latlng(*p.Point), t.Format(time.RFC3339), sourceTimelinePath))
+		}
+	}
+	if e.Visit != nil && e.Visit.TopCandidate.PlaceLocation != nil {
+		ll := latlng(*e.Visit.TopCandidate.PlaceLocation)
+		locs = append(locs,
+			segmentLocation(ll, e.StartTime, sourceVisit),
+			segmentLocation(ll, e.EndTime, sourceVisit))
+	}
+	if e.Activity != nil {
+		if g := e.Activity.Start; g != nil {
+			locs = append(locs, segmentLocation(latlng(*g), e.StartTime, sourceActivity))
+		}
+		if g := e.Activity.End; g != nil {
+			locs = append(locs, segmentLocation(latlng(*g), e.EndTime, sourceActivity))
+		}
+	}
+	return locs, nil
+}
+
+// getTimelineEntryLocations returns the locations of an iOS timeline entry sorted by time
+func getTimelineEntryLocations(data []byte) ([]Location, error) {
+	var e timelineEntry
+	err := json.Unmarshal(data, &e)
+	if err != nil {
+		return nil, err
+	}
+	locs, err := e.locations()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(locs, func(i, j int) bool { return locs[i].Timestamp < locs[j].Timestamp })
+	return locs, nil
+}
@@ -0,0 +1,84 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetSegmentLocations(t *testing.T) {
+	data := []struct {
+		in  string
+		out []Location
+	}{
+		{
+			`{"startTime": "2024-12-07T17:00:00.000+01:00", "endTime": "2024-12-07T19:00:00.000+01:00",
+			"timelinePath": [{"point": "50.6443831°, 3.0536723°", "time": "2024-12-07T17:02:00.000+01:00"}, {"point": "50.6443°, -3.05°", "time": "2024-12-07T17:01:00.000+01:00"}]}`,
+			[]Location{
+				{LatitudeE7: "506443000", LongitudeE7: "-30500000", Timestamp: "2024-12-07T16:01:00Z", Source: sourceTimelinePath, Offset: "+01:00"},
+				{LatitudeE7: "506443831", LongitudeE7: "30536723", Timestamp: "2024-12-07T16:02:00Z", Source: sourceTimelinePath, Offset: "+01:00"},
+			},
+		},
+		{
+			`{"startTime": "2024-12-07T19:00:00.000+01:00", "endTime": "2024-12-07T21:00:00.000+01:00",
+			"visit": {"hierarchyLevel": 0, "probability": 0.9, "topCandidate": {"placeId": "X", "semanticType": "HOME", "placeLocation": {"latLng": "50.6443831°, 3.0536723°"}}}}`,
+			[]Location{
+				{LatitudeE7: "506443831", LongitudeE7: "30536723", Timestamp: "2024-12-07T18:00:00Z", Source: sourceVisit, Offset: "+01:00"},
+				{LatitudeE7: "506443831", LongitudeE7: "30536723", Timestamp: "2024-12-07T20:00:00Z", Source: sourceVisit, Offset: "+01:00"},
+			},
+		},
+		{
+			`{"startTime": "2024-12-07T21:00:00.000+01:00", "endTime": "2024-12-07T21:30:00.000+01:00",
+			"activity": {"start": {"latLng": "50.6443831°, 3.0536723°"}, "end": {"latLng": "50.6553765°, 3.0632229°"}, "distanceMeters": 1234.5}}`,
+			[]Location{
+				{LatitudeE7: "506443831", LongitudeE7: "30536723", Timestamp: "2024-12-07T20:00:00Z", Source: sourceActivity, Offset: "+01:00"},
+				{LatitudeE7: "506553765", LongitudeE7: "30632229", Timestamp: "2024-12-07T20:30:00Z", Source: sourceActivity, Offset: "+01:00"},
+			},
+		},
+	}
+
+	for _, d := range data {
+		got, err := getSegmentLocations([]byte(d.in))
+		if err != nil || !reflect.DeepEqual(got, d.out) {
+			t.Errorf("getSegmentLocations(%s) = %v, %v != %v", d.in, got, err, d.out)
+		}
+	}
+}
+
+func TestGetTimelineEntryLocations(t *testing.T) {
+	data := []struct {
+		in  string
+		out []Location
+	}{
+		{
+			`{"startTime": "2024-12-07T17:00:00.000+01:00", "endTime": "2024-12-07T19:00:00.000+01:00",
+			"timelinePath": [{"point": "geo:50.644383,3.053672", "durationMinutesOffsetFromStartTime": "12"}, {"point": "geo:-50.6,3", "durationMinutesOffsetFromStartTime": "2"}]}`,
+			[]Location{
+				{LatitudeE7: "-506000000", LongitudeE7: "30000000", Timestamp: "2024-12-07T16:02:00Z", Source: sourceTimelinePath, Offset: "+01:00"},
+				{LatitudeE7: "506443830", LongitudeE7: "30536720", Timestamp: "2024-12-07T16:12:00Z", Source: sourceTimelinePath, Offset: "+01:00"},
+			},
+		},
+		{
+			`{"startTime": "2024-12-07T19:00:00.000+01:00", "endTime": "2024-12-07T21:00:00.000+01:00",
+			"visit": {"hierarchyLevel": "0", "topCandidate": {"probability": "0.9", "semanticType": "Unknown", "placeID": "X", "placeLocation": "geo:50.644383,3.053672"}, "probability": "0.8"}}`,
+			[]Location{
+				{LatitudeE7: "506443830", LongitudeE7: "30536720", Timestamp: "2024-12-07T18:00:00Z", Source: sourceVisit, Offset: "+01:00"},
+				{LatitudeE7: "506443830", LongitudeE7: "30536720", Timestamp: "2024-12-07T20:00:00Z", Source: sourceVisit, Offset: "+01:00"},
+			},
+		},
+		{
+			`{"startTime": "2024-12-07T21:00:00.000+01:00", "endTime": "2024-12-07T21:30:00.000+01:00",
+			"activity": {"probability": "0.5", "start": "geo:50.644383,3.053672", "end": "geo:50.655376,3.063222", "topCandidate": {"type": "walking", "probability": "0.5"}, "distanceMeters": "1234.5"}}`,
+			[]Location{
+				{LatitudeE7: "506443830", LongitudeE7: "30536720", Timestamp: "2024-12-07T20:00:00Z", Source: sourceActivity, Offset: "+01:00"},
+				{LatitudeE7: "506553760", LongitudeE7: "30632220", Timestamp: "2024-12-07T20:30:00Z", Source: sourceActivity, Offset: "+01:00"},
+			},
+		},
+	}
+
+	for _, d := range data {
+		got, err := getTimelineEntryLocations([]byte(d.in))
+		if err != nil || !reflect.DeepEqual(got, d.out) {
+			t.Errorf("getTimelineEntryLocations(%s) = %v, %v != %v", d.in, got, err, d.out)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package history
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// timedLocation is a location with its parsed timestamp
+type timedLocation struct {
+	Location
+	time time.Time
+}
+
+// Track is a list of locations sorted by time,
+// used to find the location at any time (to geotag photos for example)
+type Track struct {
+	points []timedLocation
+}
+
+// NewTrack returns the track of the locations, sorted by time.
+// The locations with an invalid timestamp are ignored.
+func NewTrack(locations []Location) *Track {
+	var points []timedLocation
+	for _, l := range locations {
+		t, err := time.Parse(time.RFC3339, l.Timestamp)
+		if err != nil {
+			continue
+		}
+		points = append(points, timedLocation{l, t})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].time.Before(points[j].time) })
+	return &Track{points: points}
+}
+
+// interpolate returns the location between a and b at time t.
+// The accuracy is the worst of the two accuracies.
+func interpolate(a, b timedLocation, t time.Time) (Location, error) {
+	alat, alon, err := coords(a.Location)
+	if err != nil {
+		return Location{}, err
+	}
+	blat, blon, err := coords(b.Location)
+	if err != nil {
+		return Location{}, err
+	}
+	frac := 0.0
+	if d := b.time.Sub(a.time); d > 0 {
+		frac = float64(t.Sub(a.time)) / float64(d)
+	}
+	accuracy := b.Accuracy
+	if AcceptAccuracy(b.Accuracy, string(a.Accuracy)) {
+		accuracy = a.Accuracy
+	}
+	return Location{
+		LatitudeE7:  IntString(strconv.FormatInt(alat+int64(float64(blat-alat)*frac), 10)),
+		LongitudeE7: IntString(strconv.FormatInt(alon+int64(float64(blon-alon)*frac), 10)),
+		Accuracy:    accuracy,
+		Timestamp:   t.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// At returns the location at time t.
+// The position is interpolated between the two locations bracketing t.
+// If only one of them is less than maxGap away from t, it is used as is.
+func (tr *Track) At(t time.Time, maxGap time.Duration) (Location, bool) {
+	track := tr.points
+	// the first location not before t
+	i := sort.Search(len(track), func(i int) bool { return !track[i].time.Before(t) })
+	var before, after *timedLocation
+	if i > 0 && t.Sub(track[i-1].time) <= maxGap {
+		before = &track[i-1]
+	}
+	if i < len(track) && track[i].time.Sub(t) <= maxGap {
+		after = &track[i]
+	}
+	switch {
+	case before != nil && after != nil:
+		l, err := interpolate(*before, *after, t)
+		return l, err == nil
+	case before != nil:
+		return before.Location, true
+	case after != nil:
+		return after.Location, true
+	}
+	return Location{}, false
+}
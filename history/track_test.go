@@ -0,0 +1,40 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackAt(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, _ := time.Parse(time.RFC3339, s)
+		return tm
+	}
+	locations := []Location{
+		{LatitudeE7: "510000000", LongitudeE7: "40000000", Accuracy: "5", Timestamp: "2023-07-14T12:00:00Z"},
+		{LatitudeE7: "500000000", LongitudeE7: "30000000", Accuracy: "10", Timestamp: "2023-07-14T10:00:00Z"},
+		{LatitudeE7: "500001000", LongitudeE7: "-30000000", Accuracy: "20", Timestamp: "2023-07-14T10:10:00Z"},
+		{LatitudeE7: "0", LongitudeE7: "0", Accuracy: "1", Timestamp: "invalid"},
+	}
+	track := NewTrack(locations)
+
+	data := []struct {
+		time string
+		ok   bool
+		out  Location
+	}{
+		{"2023-07-14T10:05:00Z", true, Location{LatitudeE7: "500000500", LongitudeE7: "0", Accuracy: "20", Timestamp: "2023-07-14T10:05:00Z"}},
+		{"2023-07-14T10:00:00Z", true, locations[1]},
+		{"2023-07-14T10:20:00Z", true, locations[2]},
+		{"2023-07-14T11:55:00Z", true, locations[0]},
+		{"2023-07-14T11:00:00Z", false, Location{}},
+		{"2023-07-14T09:00:00Z", false, Location{}},
+	}
+
+	for _, d := range data {
+		got, ok := track.At(at(d.time), 30*time.Minute)
+		if ok != d.ok || got != d.out {
+			t.Errorf("At(%s) = %v, %t != %v, %t", d.time, got, ok, d.out, d.ok)
+		}
+	}
+}
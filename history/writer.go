@@ -0,0 +1,207 @@
+package history
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"text/template"
+
+	"github.com/goccy/go-json"
+)
+
+// Writer is an interface for writing GPX, CSV, KML, etc.
+type Writer interface {
+	// WriteHeader writes the header
+	WriteHeader() error
+
+	// WriteLocation writes a location
+	WriteLocation(l Location) error
+
+	// WriteNewSegment writes a new segment
+	WriteNewSegment() error
+
+	// WriteNewTrack writes a new track
+	WriteNewTrack() error
+
+	// WriteWaypoint writes a point of interest
+	WriteWaypoint(w Waypoint) error
+
+	// WriteFooter writes the footer
+	WriteFooter() error
+
+	// Flush flushes the writer
+	Flush() error
+}
+
+// Waypoint is a point of interest, like a place where we stayed
+type Waypoint struct {
+	LatitudeE7  IntString
+	LongitudeE7 IntString
+	Arrival     string
+	Departure   string
+}
+
+const (
+	zero8 IntString = "00000000"
+)
+
+// E7toDec converts a latitude or longitude from e7 format to decimal
+func E7toDec(e7 IntString) string {
+	if e7 == "" {
+		return "0.0000000"
+	}
+	if e7[0] == '-' {
+		return "-" + E7toDec(e7[1:])
+	}
+	lene7 := len(e7)
+	if lene7 < 8 {
+		e7 = zero8[:8-lene7] + e7
+		lene7 = 8
+	}
+	return string(e7[:len(e7)-7] + "." + e7[len(e7)-7:])
+}
+
+// funcMap is the map of functions used in the templates
+var funcMap template.FuncMap = map[string]interface{}{}
+
+func init() {
+	funcMap["e7todec"] = E7toDec
+	funcMap["json"] = jsonString
+}
+
+// jsonString returns s as a quoted json string
+func jsonString(s string) (string, error) {
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+// TemplateWriter is a Writer using templates for the locations and the waypoints.
+// The trackStart is written before the first location, and the trackEnd before the footer
+// if some location was written. With waypointsFirst the tracks are spooled to a temporary
+// file until the footer, so the waypoints received at any time are written before them.
+// Without localTime the offsets of the locations are removed, so that only the UTC times are written.
+type TemplateWriter struct {
+	w              *bufio.Writer
+	header         string
+	trackStart     string
+	location       *template.Template
+	waypoint       *template.Template
+	newSegment     string
+	newTrack       string
+	trackEnd       string
+	footer         string
+	inTrack        bool
+	waypointsFirst bool
+	localTime      bool
+	// the spool of the tracks with waypointsFirst
+	spool  *os.File
+	tracks *bufio.Writer
+}
+
+// trackWriter returns where the tracks are written, the spool being created if needed
+func (t *TemplateWriter) trackWriter() (*bufio.Writer, error) {
+	if !t.waypointsFirst {
+		return t.w, nil
+	}
+	if t.tracks == nil {
+		spool, err := os.CreateTemp("", "gotoextr-*.tmp")
+		if err != nil {
+			return nil, err
+		}
+		// the spool is removed when closed (or now on the systems that allow it)
+		os.Remove(spool.Name())
+		t.spool, t.tracks = spool, bufio.NewWriter(spool)
+	}
+	return t.tracks, nil
+}
+
+// copySpool writes the spooled tracks to the output and removes the spool
+func (t *TemplateWriter) copySpool() error {
+	spool := t.spool
+	if spool == nil {
+		return nil
+	}
+	t.spool, t.tracks = nil, nil
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(t.w, spool)
+	return err
+}
+
+func (t *TemplateWriter) WriteHeader() error {
+	_, err := t.w.WriteString(t.header)
+	return err
+}
+
+func (t *TemplateWriter) WriteLocation(l Location) error {
+	w, err := t.trackWriter()
+	if err != nil {
+		return err
+	}
+	if !t.inTrack {
+		t.inTrack = true
+		if _, err := w.WriteString(t.trackStart); err != nil {
+			return err
+		}
+	}
+	if !t.localTime {
+		l.Offset = ""
+	}
+	return t.location.Execute(w, l)
+}
+
+// writeSeparator writes the separator between the locations of a track
+func (t *TemplateWriter) writeSeparator(separator string) error {
+	if !t.inTrack {
+		// nothing to separate from
+		return nil
+	}
+	w, err := t.trackWriter()
+	if err != nil {
+		return err
+	}
+	_, err = w.WriteString(separator)
+	return err
+}
+
+func (t *TemplateWriter) WriteNewSegment() error {
+	return t.writeSeparator(t.newSegment)
+}
+
+func (t *TemplateWriter) WriteNewTrack() error {
+	return t.writeSeparator(t.newTrack)
+}
+
+func (t *TemplateWriter) WriteWaypoint(w Waypoint) error {
+	if t.waypoint == nil {
+		// the format has no waypoints
+		return nil
+	}
+	return t.waypoint.Execute(t.w, w)
+}
+
+func (t *TemplateWriter) WriteFooter() error {
+	if t.inTrack {
+		if err := t.writeSeparator(t.trackEnd); err != nil {
+			return err
+		}
+		t.inTrack = false
+	}
+	if t.tracks != nil {
+		if err := t.tracks.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := t.copySpool(); err != nil {
+		return err
+	}
+	_, err := t.w.WriteString(t.footer)
+	return err
+}
+
+func (t *TemplateWriter) Flush() error {
+	return t.w.Flush()
+}
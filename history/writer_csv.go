@@ -0,0 +1,38 @@
+package history
+
+import (
+	"bufio"
+	"io"
+	"text/template"
+)
+
+const (
+	csvHeader      = "timestamp,lat,lon,accuracy,departure,altitude,speed,heading,vaccuracy,activity"
+	csvLocTemplate = "{{ .Timestamp }},{{ .LatitudeE7 | e7todec }},{{ .LongitudeE7 | e7todec }},{{ .Accuracy }},," +
+		"{{ .Altitude }},{{ .Speed }},{{ .Heading }},{{ .VerticalAccuracy }},{{ .Activity }}"
+	csvWptTemplate = "{{ .Arrival }},{{ .LatitudeE7 | e7todec }},{{ .LongitudeE7 | e7todec }},,{{ .Departure }},,,,,"
+	// the last column with the local time
+	csvLocalTimeHeader   = ",localtime"
+	csvLocalTimeTemplate = ",{{ .LocalTime }}"
+)
+
+// NewCSVWriter returns a Writer producing a CSV file, with a last localtime column if localTime is true
+func NewCSVWriter(w io.Writer, localTime bool) Writer {
+	header, locText, wptText := csvHeader, csvLocTemplate, csvWptTemplate
+	if localTime {
+		header, locText, wptText = header+csvLocalTimeHeader, locText+csvLocalTimeTemplate, wptText+","
+	}
+	// compile the templates
+	locTemplate := template.New("csv").Funcs(funcMap)
+	locTemplate = template.Must(locTemplate.Parse(locText + "\n"))
+	wptTemplate := template.New("csvwpt").Funcs(funcMap)
+	wptTemplate = template.Must(wptTemplate.Parse(wptText + "\n"))
+
+	return &TemplateWriter{
+		w:         bufio.NewWriter(w),
+		header:    header + "\n",
+		location:  locTemplate,
+		waypoint:  wptTemplate,
+		localTime: localTime,
+	}
+}
@@ -0,0 +1,315 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// The FIT (Flexible and Interoperable Data Transfer) files are binary:
+//
+//	header (14 bytes) | records ... | CRC-16 of all the previous bytes
+//
+// Each record is a definition message, giving the fields of a local message type,
+// or a data message of a local message type previously defined.
+// An activity file contains the messages file_id, event, record (one per location),
+// lap (one per track), session and activity.
+
+const (
+	// the FIT protocol and profile versions
+	fitProtocolVersion = 0x10
+	fitProfileVersion  = 2100
+
+	// the global message numbers
+	fitFileID   = 0
+	fitSession  = 18
+	fitLap      = 19
+	fitRecord   = 20
+	fitEvent    = 21
+	fitActivity = 34
+
+	// the base types
+	fitEnum    = 0x00
+	fitUint16  = 0x84
+	fitSint32  = 0x85
+	fitUint32  = 0x86
+	fitUint32z = 0x8C
+
+	// the values of the enum fields
+	fitFileActivity     = 4
+	fitManufacturerDev  = 255
+	fitEventTimer       = 0
+	fitEventSession     = 8
+	fitEventLap         = 9
+	fitEventActivity    = 26
+	fitEventTypeStart   = 0
+	fitEventTypeStop    = 1
+	fitEventTypeStopAll = 4
+
+	// the invalid values of the uint16 and uint32 fields
+	fitInvalidUint16 = 0xFFFF
+	fitInvalidUint32 = 0xFFFFFFFF
+)
+
+// fitEpoch is the origin of the FIT timestamps
+var fitEpoch = time.Date(1989, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// fitCRCTable is the table of the FIT CRC-16
+var fitCRCTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+// fitCRC updates the FIT CRC-16 crc with the bytes of data
+func fitCRC(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		tmp := fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[b&0xF]
+		tmp = fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[(b>>4)&0xF]
+	}
+	return crc
+}
+
+// e7toSemicircles converts a coordinate in E7 format to semicircles (2^31 semicircles = 180°)
+func e7toSemicircles(e7 int64) int32 {
+	s := e7 * (1 << 31) / 1800000000
+	return int32(s)
+}
+
+// fitScaled returns the number n as n*scale+offset in a uint16 field,
+// or the invalid value if n is unknown or out of range
+func fitScaled(n IntString, scale, offset float64) int64 {
+	x, err := n.Float64()
+	if err != nil {
+		return fitInvalidUint16
+	}
+	v := math.Round(x*scale + offset)
+	if v < 0 || v >= fitInvalidUint16 {
+		return fitInvalidUint16
+	}
+	return int64(v)
+}
+
+// fitField is a field of a FIT message
+type fitField struct {
+	num   byte
+	base  byte
+	value int64
+}
+
+// size returns the size in bytes of the field
+func (f fitField) size() byte {
+	switch f.base {
+	case fitUint16:
+		return 2
+	case fitSint32, fitUint32, fitUint32z:
+		return 4
+	}
+	return 1
+}
+
+// FITWriter is a Writer producing a FIT activity file.
+// Each track is a lap, the segments are ignored.
+// The file is kept in memory and written by WriteFooter,
+// because its header contains the size of the data.
+type FITWriter struct {
+	w    *bufio.Writer
+	body bytes.Buffer
+	// defined are the local message types already defined
+	defined [16]bool
+	// started is true once the first location is written
+	started bool
+	// first and last are the earliest and the latest times of the locations,
+	// as the history may go back in time
+	first, last uint32
+	// the current lap, with its earliest and latest times
+	inLap            bool
+	lapStart, lapEnd uint32
+	laps             int
+	// timer is the sum of the lap durations
+	timer uint32
+}
+
+// NewFITWriter returns a FITWriter writing to w
+func NewFITWriter(w io.Writer) *FITWriter {
+	return &FITWriter{w: bufio.NewWriter(w)}
+}
+
+// message writes a data message with the fields, preceded by its definition
+// the first time the local message type is used.
+// A local message type should always be used with the same global message and fields.
+func (f *FITWriter) message(local byte, global uint16, fields ...fitField) {
+	if !f.defined[local] {
+		f.defined[local] = true
+		// definition header, reserved byte and little endian architecture
+		f.body.Write([]byte{0x40 | local, 0, 0})
+		binary.Write(&f.body, binary.LittleEndian, global)
+		f.body.WriteByte(byte(len(fields)))
+		for _, fd := range fields {
+			f.body.Write([]byte{fd.num, fd.size(), fd.base})
+		}
+	}
+	f.body.WriteByte(local)
+	for _, fd := range fields {
+		switch fd.size() {
+		case 1:
+			f.body.WriteByte(byte(fd.value))
+		case 2:
+			binary.Write(&f.body, binary.LittleEndian, uint16(fd.value))
+		case 4:
+			binary.Write(&f.body, binary.LittleEndian, uint32(fd.value))
+		}
+	}
+}
+
+// fileID writes the file_id message
+func (f *FITWriter) fileID(created uint32) {
+	f.message(0, fitFileID,
+		fitField{0, fitEnum, fitFileActivity},
+		fitField{1, fitUint16, fitManufacturerDev},
+		fitField{2, fitUint16, 0},
+		fitField{3, fitUint32z, 1},
+		fitField{4, fitUint32, int64(created)})
+}
+
+// event writes an event message
+func (f *FITWriter) event(t uint32, event, eventType int64) {
+	f.message(1, fitEvent,
+		fitField{253, fitUint32, int64(t)},
+		fitField{0, fitEnum, event},
+		fitField{1, fitEnum, eventType})
+}
+
+// minMax returns the range from lo to hi extended to t
+func minMax(lo, hi, t uint32) (uint32, uint32) {
+	if t < lo {
+		lo = t
+	}
+	if t > hi {
+		hi = t
+	}
+	return lo, hi
+}
+
+// endLap writes the lap message of the current lap
+func (f *FITWriter) endLap() {
+	if !f.inLap {
+		return
+	}
+	f.inLap = false
+	elapsed := int64(f.lapEnd-f.lapStart) * 1000
+	f.timer += uint32(elapsed)
+	f.message(3, fitLap,
+		fitField{253, fitUint32, int64(f.lapEnd)},
+		fitField{254, fitUint16, int64(f.laps)},
+		fitField{2, fitUint32, int64(f.lapStart)},
+		fitField{7, fitUint32, elapsed},
+		fitField{8, fitUint32, elapsed},
+		fitField{0, fitEnum, fitEventLap},
+		fitField{1, fitEnum, fitEventTypeStop})
+	f.laps++
+}
+
+func (f *FITWriter) WriteHeader() error {
+	// the header is written with the data in WriteFooter
+	return nil
+}
+
+func (f *FITWriter) WriteLocation(l Location) error {
+	t, err := time.Parse(time.RFC3339, l.Timestamp)
+	if err != nil || t.Before(fitEpoch) {
+		// the FIT records need a valid timestamp
+		return nil
+	}
+	lat, lon, err := coords(l)
+	if err != nil {
+		return nil
+	}
+	ft := uint32(t.Sub(fitEpoch) / time.Second)
+	if !f.started {
+		f.started = true
+		f.first, f.last = ft, ft
+		f.fileID(ft)
+		f.event(ft, fitEventTimer, fitEventTypeStart)
+	}
+	if !f.inLap {
+		f.inLap = true
+		f.lapStart, f.lapEnd = ft, ft
+	}
+	f.first, f.last = minMax(f.first, f.last, ft)
+	f.lapStart, f.lapEnd = minMax(f.lapStart, f.lapEnd, ft)
+	f.message(2, fitRecord,
+		fitField{253, fitUint32, int64(ft)},
+		fitField{0, fitSint32, int64(e7toSemicircles(lat))},
+		fitField{1, fitSint32, int64(e7toSemicircles(lon))},
+		// the altitude in 1/5 m from -500 m and the speed in mm/s
+		fitField{2, fitUint16, fitScaled(l.Altitude, 5, 500*5)},
+		fitField{6, fitUint16, fitScaled(l.Speed, 1000, 0)})
+	return nil
+}
+
+func (f *FITWriter) WriteNewSegment() error {
+	return nil
+}
+
+func (f *FITWriter) WriteNewTrack() error {
+	f.endLap()
+	return nil
+}
+
+func (f *FITWriter) WriteWaypoint(w Waypoint) error {
+	// the activity files have no waypoints
+	return nil
+}
+
+func (f *FITWriter) WriteFooter() error {
+	if !f.started {
+		// an activity file without activity
+		f.fileID(fitInvalidUint32)
+	} else {
+		f.endLap()
+		f.event(f.last, fitEventTimer, fitEventTypeStopAll)
+		f.message(4, fitSession,
+			fitField{253, fitUint32, int64(f.last)},
+			fitField{2, fitUint32, int64(f.first)},
+			fitField{7, fitUint32, int64(f.last-f.first) * 1000},
+			fitField{8, fitUint32, int64(f.timer)},
+			fitField{5, fitEnum, 0},
+			fitField{25, fitUint16, 0},
+			fitField{26, fitUint16, int64(f.laps)},
+			fitField{0, fitEnum, fitEventSession},
+			fitField{1, fitEnum, fitEventTypeStop})
+		f.message(5, fitActivity,
+			fitField{253, fitUint32, int64(f.last)},
+			fitField{0, fitUint32, int64(f.timer)},
+			fitField{1, fitUint16, 1},
+			fitField{2, fitEnum, 0},
+			fitField{3, fitEnum, fitEventActivity},
+			fitField{4, fitEnum, fitEventTypeStop})
+	}
+
+	// the header
+	header := make([]byte, 14)
+	header[0] = 14
+	header[1] = fitProtocolVersion
+	binary.LittleEndian.PutUint16(header[2:], fitProfileVersion)
+	binary.LittleEndian.PutUint32(header[4:], uint32(f.body.Len()))
+	copy(header[8:], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:], fitCRC(0, header[:12]))
+
+	// the file CRC covers the header and the data
+	crc := fitCRC(fitCRC(0, header), f.body.Bytes())
+	f.w.Write(header)
+	f.w.Write(f.body.Bytes())
+	return binary.Write(f.w, binary.LittleEndian, crc)
+}
+
+func (f *FITWriter) Flush() error {
+	return f.w.Flush()
+}
@@ -0,0 +1,183 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestFitCRC(t *testing.T) {
+	if crc := fitCRC(0, []byte("123456789")); crc != 0xBB3D {
+		t.Errorf("fitCRC(123456789) = %04X != BB3D", crc)
+	}
+}
+
+func TestE7toSemicircles(t *testing.T) {
+	data := []struct {
+		in  int64
+		out int32
+	}{
+		{0, 0},
+		{900000000, 1 << 30},
+		{-900000000, -(1 << 30)},
+		{506443831, 604211025},
+	}
+
+	for _, d := range data {
+		if got := e7toSemicircles(d.in); got != d.out {
+			t.Errorf("e7toSemicircles(%d) = %d != %d", d.in, got, d.out)
+		}
+	}
+}
+
+// fitMessage is a decoded FIT data message
+type fitMessage struct {
+	global uint16
+	fields map[byte]uint32
+}
+
+// decodeFIT checks the header and the CRC of a FIT file and returns its data messages
+func decodeFIT(t *testing.T, data []byte) []fitMessage {
+	t.Helper()
+	if len(data) < 16 || data[0] != 14 || string(data[8:12]) != ".FIT" {
+		t.Fatalf("invalid FIT header % X", data)
+	}
+	if fitCRC(0, data[:12]) != binary.LittleEndian.Uint16(data[12:]) {
+		t.Errorf("invalid header CRC")
+	}
+	size := int(binary.LittleEndian.Uint32(data[4:]))
+	if 14+size+2 != len(data) {
+		t.Fatalf("data size %d in a file of %d bytes", size, len(data))
+	}
+	// the CRC of the data followed by its CRC is 0
+	if fitCRC(0, data) != 0 {
+		t.Errorf("invalid file CRC")
+	}
+
+	type definition struct {
+		global uint16
+		fields [][2]byte
+	}
+	definitions := map[byte]definition{}
+	var messages []fitMessage
+	body := data[14 : 14+size]
+	for len(body) > 0 {
+		header := body[0]
+		local := header & 0x0F
+		if header&0x40 != 0 {
+			d := definition{global: binary.LittleEndian.Uint16(body[3:])}
+			n := int(body[5])
+			for i := 0; i < n; i++ {
+				d.fields = append(d.fields, [2]byte{body[6+3*i], body[7+3*i]})
+			}
+			definitions[local] = d
+			body = body[6+3*n:]
+			continue
+		}
+		d, ok := definitions[local]
+		if !ok {
+			t.Fatalf("undefined local message %d", local)
+		}
+		m := fitMessage{global: d.global, fields: map[byte]uint32{}}
+		body = body[1:]
+		for _, f := range d.fields {
+			switch f[1] {
+			case 1:
+				m.fields[f[0]] = uint32(body[0])
+			case 2:
+				m.fields[f[0]] = uint32(binary.LittleEndian.Uint16(body))
+			case 4:
+				m.fields[f[0]] = binary.LittleEndian.Uint32(body)
+			}
+			body = body[f[1]:]
+		}
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+func TestFITWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := NewFITWriter(&b)
+	w.WriteHeader()
+	w.WriteNewTrack()
+	w.WriteLocation(Location{LatitudeE7: "506443831", LongitudeE7: "30536723", Timestamp: "1989-12-31T00:01:00Z", Altitude: "65.3", Speed: "1.5"})
+	w.WriteNewSegment()
+	w.WriteLocation(Location{LatitudeE7: "506443832", LongitudeE7: "-30536723", Timestamp: "1989-12-31T00:02:00Z"})
+	w.WriteLocation(Location{LatitudeE7: "506443833", LongitudeE7: "30536723", Timestamp: "invalid"})
+	w.WriteNewTrack()
+	w.WriteLocation(Location{LatitudeE7: "506443834", LongitudeE7: "30536723", Timestamp: "1989-12-31T01:00:00.5Z"})
+	w.WriteLocation(Location{LatitudeE7: "506443835", LongitudeE7: "30536723", Timestamp: "1989-12-31T01:30:00Z"})
+	w.WriteFooter()
+	w.Flush()
+
+	var globals []uint16
+	var records []uint32
+	var motion [][2]uint32
+	var laps [][2]uint32
+	for _, m := range decodeFIT(t, b.Bytes()) {
+		globals = append(globals, m.global)
+		switch m.global {
+		case fitRecord:
+			records = append(records, m.fields[253])
+			motion = append(motion, [2]uint32{m.fields[2], m.fields[6]})
+		case fitLap:
+			laps = append(laps, [2]uint32{m.fields[2], m.fields[7]})
+		case fitSession:
+			if m.fields[2] != 60 || m.fields[7] != 5340000 || m.fields[8] != 1860000 || m.fields[26] != 2 {
+				t.Errorf("session = %v", m.fields)
+			}
+		}
+	}
+	expected := []uint16{fitFileID, fitEvent, fitRecord, fitRecord, fitLap, fitRecord, fitRecord, fitLap, fitEvent, fitSession, fitActivity}
+	if len(globals) != len(expected) {
+		t.Fatalf("messages %v != %v", globals, expected)
+	}
+	for i := range expected {
+		if globals[i] != expected[i] {
+			t.Fatalf("messages %v != %v", globals, expected)
+		}
+	}
+	if len(records) != 4 || records[0] != 60 || records[1] != 120 || records[2] != 3600 || records[3] != 5400 {
+		t.Errorf("record times %v", records)
+	}
+	// the altitude and the speed of the first record, unknown in the second
+	if len(motion) != 4 || motion[0] != [2]uint32{2827, 1500} || motion[1] != [2]uint32{fitInvalidUint16, fitInvalidUint16} {
+		t.Errorf("record altitudes and speeds %v", motion)
+	}
+	if len(laps) != 2 || laps[0] != [2]uint32{60, 60000} || laps[1] != [2]uint32{3600, 1800000} {
+		t.Errorf("laps %v", laps)
+	}
+}
+
+func TestFITWriterWithoutLocation(t *testing.T) {
+	var b bytes.Buffer
+	w := NewFITWriter(&b)
+	w.WriteHeader()
+	w.WriteFooter()
+	w.Flush()
+	if m := decodeFIT(t, b.Bytes()); len(m) != 1 || m[0].global != fitFileID {
+		t.Errorf("empty FIT messages %v", m)
+	}
+}
+
+func TestFITWriterBackwards(t *testing.T) {
+	var b bytes.Buffer
+	w := NewFITWriter(&b)
+	w.WriteHeader()
+	// the second location is a minute before the first one
+	w.WriteLocation(Location{LatitudeE7: "506443831", LongitudeE7: "30536723", Timestamp: "1989-12-31T00:02:00Z"})
+	w.WriteLocation(Location{LatitudeE7: "506443832", LongitudeE7: "30536723", Timestamp: "1989-12-31T00:01:00Z"})
+	w.WriteLocation(Location{LatitudeE7: "506443833", LongitudeE7: "30536723", Timestamp: "1989-12-31T00:01:30Z"})
+	w.WriteFooter()
+	w.Flush()
+
+	for _, m := range decodeFIT(t, b.Bytes()) {
+		switch m.global {
+		case fitLap, fitSession:
+			if m.fields[2] != 60 || m.fields[7] != 60000 || m.fields[253] != 120 {
+				t.Errorf("message %d = %v", m.global, m.fields)
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package history
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"text/template"
+)
+
+const (
+	geojsonHeader = `{"type":"FeatureCollection","features":[`
+	geojsonFooter = "\n]}\n"
+	// the waypoints are Point features
+	geojsonWptTemplate = `{"type":"Feature","geometry":{"type":"Point","coordinates":[{{ .LongitudeE7 | e7todec }},{{ .LatitudeE7 | e7todec }}]},` +
+		`"properties":{"name":"Stay","arrival":{{ json .Arrival }},"departure":{{ json .Departure }}}}`
+	// RFC 8142 : each feature starts with the record separator and ends with a new line
+	geojsonseqLocTemplate = "\x1e" + `{"type":"Feature","geometry":{"type":"Point","coordinates":[{{ .LongitudeE7 | e7todec }},{{ .LatitudeE7 | e7todec }}{{ with .Altitude }},{{ . }}{{ end }}]},` +
+		`"properties":{"time":{{ json .Timestamp }}{{ with .Accuracy }},"accuracy":{{ . }}{{ end }}{{ with .Source }},"source":{{ json . }}{{ end }}{{ with .LocalTime }},"localTime":{{ json . }}{{ end }}` +
+		`{{ with .Speed }},"speed":{{ . }}{{ end }}{{ with .Heading }},"heading":{{ . }}{{ end }}{{ with .VerticalAccuracy }},"verticalAccuracy":{{ . }}{{ end }}` +
+		`{{ with .Activity }},"activity":{{ json . }}{{ end }}}}` + "\n"
+	geojsonseqWptTemplate = "\x1e" + geojsonWptTemplate + "\n"
+)
+
+// GeoJSONWriter is a Writer producing a GeoJSON FeatureCollection
+// with one MultiLineString feature per track, the segments being its lines.
+// The timestamps of the points are in the coordTimes property of the feature,
+// with the same structure as the coordinates.
+type GeoJSONWriter struct {
+	w        *bufio.Writer
+	waypoint *template.Template
+	// features is the number of features written, to separate them with commas
+	features int
+	// inTrack is true when a track feature is open
+	inTrack bool
+	// newLine is true when the next location starts a new line (segment)
+	newLine bool
+	// times are the timestamps of the open track, one slice per line
+	times [][]string
+	// waypoints are the waypoints received inside a track, written after it
+	waypoints []Waypoint
+}
+
+// NewGeoJSONWriter returns a GeoJSONWriter writing to w
+func NewGeoJSONWriter(w io.Writer) *GeoJSONWriter {
+	return &GeoJSONWriter{
+		w:        bufio.NewWriter(w),
+		waypoint: template.Must(template.New("geojsonwpt").Funcs(funcMap).Parse(geojsonWptTemplate)),
+	}
+}
+
+// startFeature writes the separator before a new feature
+func (g *GeoJSONWriter) startFeature() error {
+	sep := "\n"
+	if g.features > 0 {
+		sep = ",\n"
+	}
+	g.features++
+	_, err := g.w.WriteString(sep)
+	return err
+}
+
+// endTrack closes the open track feature and writes the waypoints received inside it
+func (g *GeoJSONWriter) endTrack() error {
+	if !g.inTrack {
+		return nil
+	}
+	g.inTrack = false
+	g.w.WriteString(`]]},"properties":{"coordTimes":[`)
+	for i, times := range g.times {
+		if i > 0 {
+			g.w.WriteString(",")
+		}
+		g.w.WriteString("[")
+		for j, t := range times {
+			if j > 0 {
+				g.w.WriteString(",")
+			}
+			s, err := jsonString(t)
+			if err != nil {
+				return err
+			}
+			g.w.WriteString(s)
+		}
+		g.w.WriteString("]")
+	}
+	g.times = g.times[:0]
+	if _, err := g.w.WriteString("]}}"); err != nil {
+		return err
+	}
+	waypoints := g.waypoints
+	g.waypoints = nil
+	for _, w := range waypoints {
+		if err := g.WriteWaypoint(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GeoJSONWriter) WriteHeader() error {
+	_, err := g.w.WriteString(geojsonHeader)
+	return err
+}
+
+func (g *GeoJSONWriter) WriteLocation(l Location) error {
+	var b strings.Builder
+	switch {
+	case !g.inTrack:
+		// open the track feature
+		if err := g.startFeature(); err != nil {
+			return err
+		}
+		g.inTrack, g.newLine = true, false
+		g.times = append(g.times, nil)
+		b.WriteString(`{"type":"Feature","geometry":{"type":"MultiLineString","coordinates":[[`)
+	case g.newLine:
+		g.newLine = false
+		g.times = append(g.times, nil)
+		b.WriteString("],[")
+	default:
+		b.WriteString(",")
+	}
+	b.WriteString("[")
+	b.WriteString(E7toDec(l.LongitudeE7))
+	b.WriteString(",")
+	b.WriteString(E7toDec(l.LatitudeE7))
+	if l.Altitude != "" {
+		b.WriteString(",")
+		b.WriteString(string(l.Altitude))
+	}
+	b.WriteString("]")
+	g.times[len(g.times)-1] = append(g.times[len(g.times)-1], l.Timestamp)
+	_, err := g.w.WriteString(b.String())
+	return err
+}
+
+func (g *GeoJSONWriter) WriteNewSegment() error {
+	// the line is started lazily, so there are no empty lines
+	g.newLine = g.inTrack
+	return nil
+}
+
+func (g *GeoJSONWriter) WriteNewTrack() error {
+	return g.endTrack()
+}
+
+func (g *GeoJSONWriter) WriteWaypoint(w Waypoint) error {
+	if g.inTrack {
+		// the track feature is not finished
+		g.waypoints = append(g.waypoints, w)
+		return nil
+	}
+	if err := g.startFeature(); err != nil {
+		return err
+	}
+	return g.waypoint.Execute(g.w, w)
+}
+
+func (g *GeoJSONWriter) WriteFooter() error {
+	if err := g.endTrack(); err != nil {
+		return err
+	}
+	_, err := g.w.WriteString(geojsonFooter)
+	return err
+}
+
+func (g *GeoJSONWriter) Flush() error {
+	return g.w.Flush()
+}
+
+// NewGeoJSONSeqWriter returns a Writer producing a GeoJSON text sequence (RFC 8142)
+// with one Point feature per location, the tracks and segments being ignored,
+// and a localTime property if localTime is true
+func NewGeoJSONSeqWriter(w io.Writer, localTime bool) Writer {
+	// compile the templates
+	locTemplate := template.New("geojsonseq").Funcs(funcMap)
+	locTemplate = template.Must(locTemplate.Parse(geojsonseqLocTemplate))
+	wptTemplate := template.New("geojsonseqwpt").Funcs(funcMap)
+	wptTemplate = template.Must(wptTemplate.Parse(geojsonseqWptTemplate))
+
+	return &TemplateWriter{
+		w:         bufio.NewWriter(w),
+		location:  locTemplate,
+		waypoint:  wptTemplate,
+		localTime: localTime,
+	}
+}
@@ -0,0 +1,89 @@
+package history
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func TestGeoJSONWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := NewGeoJSONWriter(&b)
+	w.WriteHeader()
+	w.WriteNewTrack()
+	w.WriteLocation(Location{LatitudeE7: "506443831", LongitudeE7: "30536723", Timestamp: "2024-12-07T16:00:00Z"})
+	w.WriteWaypoint(Waypoint{LatitudeE7: "506443831", LongitudeE7: "30536723", Arrival: "2024-12-07T16:00:00Z", Departure: "2024-12-07T17:00:00Z"})
+	w.WriteNewSegment()
+	w.WriteNewSegment()
+	w.WriteLocation(Location{LatitudeE7: "506443832", LongitudeE7: "30536724", Timestamp: "2024-12-07T16:01:00Z"})
+	w.WriteLocation(Location{LatitudeE7: "506443833", LongitudeE7: "30536725", Timestamp: "2024-12-07T16:02:00Z"})
+	w.WriteNewTrack()
+	w.WriteLocation(Location{LatitudeE7: "-506443834", LongitudeE7: "-30536726", Timestamp: "2024-12-07T18:00:00Z"})
+	w.WriteFooter()
+	w.Flush()
+
+	var fc struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates json.RawMessage
+			}
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(b.Bytes(), &fc); err != nil {
+		t.Fatalf("invalid GeoJSON %v: %s", err, b.String())
+	}
+	expected := []struct{ typ, coords string }{
+		{"MultiLineString", "[[[3.0536723,50.6443831]],[[3.0536724,50.6443832],[3.0536725,50.6443833]]]"},
+		{"Point", "[3.0536723,50.6443831]"},
+		{"MultiLineString", "[[[-3.0536726,-50.6443834]]]"},
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != len(expected) {
+		t.Fatalf("GeoJSON = %s", b.String())
+	}
+	for i, e := range expected {
+		f := fc.Features[i]
+		if f.Geometry.Type != e.typ || string(f.Geometry.Coordinates) != e.coords {
+			t.Errorf("feature %d = %s %s != %s %s", i, f.Geometry.Type, f.Geometry.Coordinates, e.typ, e.coords)
+		}
+	}
+	times, _ := json.Marshal(fc.Features[0].Properties["coordTimes"])
+	if string(times) != `[["2024-12-07T16:00:00Z"],["2024-12-07T16:01:00Z","2024-12-07T16:02:00Z"]]` {
+		t.Errorf("coordTimes = %s", times)
+	}
+}
+
+func TestGeoJSONWriterWithoutLocation(t *testing.T) {
+	var b bytes.Buffer
+	w := NewGeoJSONWriter(&b)
+	w.WriteHeader()
+	w.WriteNewTrack()
+	w.WriteFooter()
+	w.Flush()
+	if b.String() != geojsonHeader+geojsonFooter {
+		t.Errorf("empty GeoJSON = %s", b.String())
+	}
+}
+
+func TestGeoJSONSeqWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := NewGeoJSONSeqWriter(&b, false)
+	w.WriteHeader()
+	w.WriteLocation(Location{LatitudeE7: "506443831", LongitudeE7: "30536723", Accuracy: "13", Timestamp: "2024-12-07T16:00:00Z", Source: "GPS"})
+	w.WriteNewTrack()
+	w.WriteLocation(Location{LatitudeE7: "506443832", LongitudeE7: "30536724", Timestamp: "2024-12-07T16:01:00Z"})
+	w.WriteFooter()
+	w.Flush()
+
+	expected := []string{
+		`{"type":"Feature","geometry":{"type":"Point","coordinates":[3.0536723,50.6443831]},"properties":{"time":"2024-12-07T16:00:00Z","accuracy":13,"source":"GPS"}}`,
+		`{"type":"Feature","geometry":{"type":"Point","coordinates":[3.0536724,50.6443832]},"properties":{"time":"2024-12-07T16:01:00Z"}}`,
+	}
+	if b.String() != "\x1e"+strings.Join(expected, "\n\x1e")+"\n" {
+		t.Errorf("GeoJSON sequence = %q", b.String())
+	}
+}
@@ -0,0 +1,77 @@
+package history
+
+import (
+	"bufio"
+	"io"
+	"text/template"
+)
+
+const (
+	// the speed and the course are in the Garmin TrackPointExtension,
+	// the vertical accuracy and the local time in the gotoextr namespace
+	gpxHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" version="1.1" creator="Google Latitude JSON Converter" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v2" xmlns:gotoextr="https://github.com/kpym/gotoextr" xsi:schemaLocation="http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd http://www.garmin.com/xmlschemas/TrackPointExtension/v2 http://www.garmin.com/xmlschemas/TrackPointExtensionv2.xsd">
+	<metadata>
+		<name>Location History</name>
+	</metadata>`
+	gpxTrackStart = `
+	<trk>
+		<trkseg>`
+	gpxLocTemplate = `
+			<trkpt lat="{{ .LatitudeE7 | e7todec }}" lon="{{ .LongitudeE7 | e7todec }}">{{ with .Altitude }}
+				<ele>{{ . }}</ele>{{ end }}
+				<time>{{ .Timestamp }}</time>
+				<accuracy>{{ .Accuracy }}</accuracy>{{ if or .Speed .Heading .VerticalAccuracy .LocalTime }}
+				<extensions>{{ if or .Speed .Heading }}
+					<gpxtpx:TrackPointExtension>{{ with .Speed }}
+						<gpxtpx:speed>{{ . }}</gpxtpx:speed>{{ end }}{{ with .Heading }}
+						<gpxtpx:course>{{ . }}</gpxtpx:course>{{ end }}
+					</gpxtpx:TrackPointExtension>{{ end }}{{ with .VerticalAccuracy }}
+					<gotoextr:vaccuracy>{{ . }}</gotoextr:vaccuracy>{{ end }}{{ with .LocalTime }}
+					<gotoextr:localtime>{{ . }}</gotoextr:localtime>{{ end }}
+				</extensions>{{ end }}
+			</trkpt>`
+	gpxWptTemplate = `
+	<wpt lat="{{ .LatitudeE7 | e7todec }}" lon="{{ .LongitudeE7 | e7todec }}">
+		<time>{{ .Arrival }}</time>
+		<name>Stay</name>
+		<desc>From {{ .Arrival }} to {{ .Departure }}</desc>
+	</wpt>`
+	gpxNewTrack = `
+		</trkseg>
+	</trk>
+	<trk>
+		<trkseg>`
+	gpxNewSegment = `
+		</trkseg>
+		<trkseg>`
+	gpxTrackEnd = `
+		</trkseg>
+	</trk>`
+	gpxFooter = `
+</gpx>
+`
+)
+
+// NewGPXWriter returns a Writer producing a GPX file, with the local times in the extensions if localTime is true
+func NewGPXWriter(w io.Writer, localTime bool) Writer {
+	// compile the templates
+	locTemplate := template.New("gpx").Funcs(funcMap)
+	locTemplate = template.Must(locTemplate.Parse(gpxLocTemplate))
+	wptTemplate := template.New("gpxwpt").Funcs(funcMap)
+	wptTemplate = template.Must(wptTemplate.Parse(gpxWptTemplate))
+
+	return &TemplateWriter{
+		w:              bufio.NewWriter(w),
+		header:         gpxHeader,
+		trackStart:     gpxTrackStart,
+		location:       locTemplate,
+		waypoint:       wptTemplate,
+		newSegment:     gpxNewSegment,
+		newTrack:       gpxNewTrack,
+		trackEnd:       gpxTrackEnd,
+		footer:         gpxFooter,
+		waypointsFirst: true,
+		localTime:      localTime,
+	}
+}
@@ -0,0 +1,53 @@
+package history
+
+import (
+	"bufio"
+	"io"
+	"text/template"
+)
+
+const (
+	kmlHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+	<Document>
+		<name>Location History</name>`
+	kmlLocTemplate = `
+		<Placemark>
+			<TimeStamp><when>{{ or .LocalTime .Timestamp }}</when></TimeStamp>
+			<ExtendedData>
+				<Data name="accuracy"><value>{{ .Accuracy }}</value></Data>{{ with .Speed }}
+				<Data name="speed"><value>{{ . }}</value></Data>{{ end }}{{ with .Heading }}
+				<Data name="heading"><value>{{ . }}</value></Data>{{ end }}{{ with .VerticalAccuracy }}
+				<Data name="verticalAccuracy"><value>{{ . }}</value></Data>{{ end }}
+			</ExtendedData>
+			<Point>{{ if .Altitude }}<altitudeMode>absolute</altitudeMode>{{ end }}<coordinates>{{ .LongitudeE7 | e7todec }},{{ .LatitudeE7 | e7todec }}{{ with .Altitude }},{{ . }}{{ end }}</coordinates></Point>
+		</Placemark>`
+	kmlWptTemplate = `
+		<Placemark>
+			<name>Stay</name>
+			<TimeSpan><begin>{{ .Arrival }}</begin><end>{{ .Departure }}</end></TimeSpan>
+			<Point><coordinates>{{ .LongitudeE7 | e7todec }},{{ .LatitudeE7 | e7todec }}</coordinates></Point>
+		</Placemark>`
+	kmlFooter = `
+	</Document>
+</kml>
+`
+)
+
+// NewKMLWriter returns a Writer producing a KML file, the times being local if localTime is true
+func NewKMLWriter(w io.Writer, localTime bool) Writer {
+	// compile the templates
+	locTemplate := template.New("kml").Funcs(funcMap)
+	locTemplate = template.Must(locTemplate.Parse(kmlLocTemplate))
+	wptTemplate := template.New("kmlwpt").Funcs(funcMap)
+	wptTemplate = template.Must(wptTemplate.Parse(kmlWptTemplate))
+
+	return &TemplateWriter{
+		w:         bufio.NewWriter(w),
+		header:    kmlHeader,
+		location:  locTemplate,
+		waypoint:  wptTemplate,
+		footer:    kmlFooter,
+		localTime: localTime,
+	}
+}
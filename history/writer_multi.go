@@ -0,0 +1,50 @@
+package history
+
+// multiWriter is a Writer duplicating everything to several writers
+type multiWriter struct {
+	writers []Writer
+}
+
+// MultiWriter returns a Writer that writes everything to all the writers, in order.
+// It stops at the first error, and returns it.
+func MultiWriter(writers ...Writer) Writer {
+	return &multiWriter{writers: writers}
+}
+
+// each calls f for each writer, up to the first error
+func (m *multiWriter) each(f func(w Writer) error) error {
+	for _, w := range m.writers {
+		if err := f(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *multiWriter) WriteHeader() error {
+	return m.each(Writer.WriteHeader)
+}
+
+func (m *multiWriter) WriteLocation(l Location) error {
+	return m.each(func(w Writer) error { return w.WriteLocation(l) })
+}
+
+func (m *multiWriter) WriteNewSegment() error {
+	return m.each(Writer.WriteNewSegment)
+}
+
+func (m *multiWriter) WriteNewTrack() error {
+	return m.each(Writer.WriteNewTrack)
+}
+
+func (m *multiWriter) WriteWaypoint(wp Waypoint) error {
+	return m.each(func(w Writer) error { return w.WriteWaypoint(wp) })
+}
+
+func (m *multiWriter) WriteFooter() error {
+	return m.each(Writer.WriteFooter)
+}
+
+func (m *multiWriter) Flush() error {
+	return m.each(Writer.Flush)
+}
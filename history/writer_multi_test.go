@@ -0,0 +1,35 @@
+package history
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// failing is a Writer whose locations fail
+type failing struct {
+	recorder
+}
+
+func (f *failing) WriteLocation(l Location) error {
+	return errors.New("failed")
+}
+
+func TestMultiWriter(t *testing.T) {
+	a, b := &recorder{}, &recorder{}
+	w := MultiWriter(a, b)
+	w.WriteHeader()
+	w.WriteLocation(Location{Timestamp: "2024-12-07T16:00:00Z"})
+	w.WriteNewTrack()
+	w.WriteWaypoint(Waypoint{Arrival: "2024-12-07T16:00:00Z"})
+	w.WriteFooter()
+	if len(a.events) != 5 || !reflect.DeepEqual(a.events, b.events) {
+		t.Errorf("events %v and %v", a.events, b.events)
+	}
+
+	c := &recorder{}
+	w = MultiWriter(&failing{}, c)
+	if err := w.WriteLocation(Location{}); err == nil || len(c.events) != 0 {
+		t.Errorf("MultiWriter should stop at the first error, got %v and %v", err, c.events)
+	}
+}
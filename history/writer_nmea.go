@@ -1,4 +1,4 @@
-package main
+package history
 
 import (
 	"bufio"
@@ -63,6 +63,18 @@ func AccuracyToHDOP(accuracy IntString) string {
 	return fmt.Sprintf("%.1f", acc/4)
 }
 
+// nmeaNumber formats the number with one decimal, or returns unknown if it is not known
+func nmeaNumber(n IntString, factor float64, unknown string) string {
+	x, err := n.Float64()
+	if err != nil {
+		return unknown
+	}
+	return fmt.Sprintf("%.1f", x*factor)
+}
+
+// knotsPerMeterSecond converts the speeds from m/s to knots
+const knotsPerMeterSecond = 3600.0 / 1852
+
 // NMEA convert location to GPGGA and GPRMC NMEA sentences
 func NMEA(l Location) string {
 	// convert timestamp to NMEA format
@@ -75,8 +87,11 @@ func NMEA(l Location) string {
 	// latitude and longitude in NMEA format
 	lat, lon := latE7nmea(l.LatitudeE7), lonE7nmea(l.LongitudeE7)
 	// convert latitude to NMEA format
-	gpgga := fmt.Sprintf("GPGGA,%s,%s,%s,1,04,%s,0,M,,,,0000", t, lat, lon, AccuracyToHDOP(l.Accuracy))
-	gprmc := fmt.Sprintf("GPRMC,%s,A,%s,%s,0.0,0.0,%s,,,A", t, lat, lon, d)
+	// the altitude, speed and course, 0 if unknown
+	alt := nmeaNumber(l.Altitude, 1, "0")
+	speed, course := nmeaNumber(l.Speed, knotsPerMeterSecond, "0.0"), nmeaNumber(l.Heading, 1, "0.0")
+	gpgga := fmt.Sprintf("GPGGA,%s,%s,%s,1,04,%s,%s,M,,,,0000", t, lat, lon, AccuracyToHDOP(l.Accuracy), alt)
+	gprmc := fmt.Sprintf("GPRMC,%s,A,%s,%s,%s,%s,%s,,,A", t, lat, lon, speed, course, d)
 	return fmt.Sprintf("$%s*%s\n$%s*%s", gpgga, CRC(gpgga), gprmc, CRC(gprmc))
 }
 
@@ -1,4 +1,4 @@
-package main
+package history
 
 import (
 	"testing"
@@ -86,6 +86,10 @@ func TestNMEA(t *testing.T) {
 			Location{Timestamp: "2021-05-31T00:02:53Z", LatitudeE7: "485000000", LongitudeE7: "11310000", Accuracy: "14"},
 			"$GPGGA,000253,4830.000,N,00107.860,E,1,04,3.5,0,M,,,,0000*0E\n$GPRMC,000253,A,4830.000,N,00107.860,E,0.0,0.0,310521,,,A*77",
 		},
+		{
+			Location{Timestamp: "2021-05-31T00:02:53Z", LatitudeE7: "485000000", LongitudeE7: "11310000", Accuracy: "14", Altitude: "65.3", Speed: "10", Heading: "271"},
+			"$GPGGA,000253,4830.000,N,00107.860,E,1,04,3.5,65.3,M,,,,0000*20\n$GPRMC,000253,A,4830.000,N,00107.860,E,19.4,271.0,310521,,,A*4F",
+		},
 	}
 
 	for _, d := range data {
@@ -1,4 +1,4 @@
-package main
+package history
 
 import (
 	"bufio"
@@ -9,7 +9,8 @@ import (
 const (
 	tcxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
 <TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2 http://www.garmin.com/xmlschemas/TrainingCenterDatabasev2.xsd">
-	<Courses>
+	<Courses>`
+	tcxTrackStart = `
 		<Course>
 			<Name>New Course</Name>
 			<Track>`
@@ -19,7 +20,13 @@ const (
 					<Position>
 						<LatitudeDegrees>{{ .LatitudeE7 | e7todec }}</LatitudeDegrees>
 						<LongitudeDegrees>{{ .LongitudeE7 | e7todec }}</LongitudeDegrees>
-					</Position>
+					</Position>{{ with .Altitude }}
+					<AltitudeMeters>{{ . }}</AltitudeMeters>{{ end }}{{ with .Speed }}
+					<Extensions>
+						<TPX xmlns="http://www.garmin.com/xmlschemas/ActivityExtension/v2">
+							<Speed>{{ . }}</Speed>
+						</TPX>
+					</Extensions>{{ end }}
 				</Trackpoint>`
 	tcxNewTrack = `
 			</Track>
@@ -29,9 +36,10 @@ const (
 	tcxNewSegment = `
 			</Track>
 			<Track>`
-	tcxFooter = `
+	tcxTrackEnd = `
 			</Track>
-		</Course>
+		</Course>`
+	tcxFooter = `
 	</Courses>
 </TrainingCenterDatabase>
 `
@@ -45,9 +53,11 @@ func NewTCXWriter(w io.Writer) Writer {
 	return &TemplateWriter{
 		w:          bufio.NewWriter(w),
 		header:     tcxHeader,
+		trackStart: tcxTrackStart,
 		location:   locTemplate,
 		newSegment: tcxNewSegment,
 		newTrack:   tcxNewTrack,
+		trackEnd:   tcxTrackEnd,
 		footer:     tcxFooter,
 	}
 }
@@ -0,0 +1,104 @@
+package history
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// The user templates define the blocks of the output:
+//
+//	{{ define "header" }}...{{ end }}
+//	{{ define "location" }}...{{ end }}
+//	{{ define "newSegment" }}...{{ end }}
+//	{{ define "newTrack" }}...{{ end }}
+//	{{ define "footer" }}...{{ end }}
+//
+// and optionally "trackStart", "trackEnd" and "waypoint".
+// The location block is executed with a Location, the waypoint block with a Waypoint,
+// and the other blocks without data. Only the location block is required.
+
+func init() {
+	funcMap["date"] = formatDate
+	funcMap["unix"] = unixTime
+	funcMap["e7tofloat"] = e7toFloat
+	funcMap["round"] = round
+}
+
+// formatDate formats the RFC3339 timestamp with the Go time layout
+func formatDate(layout, timestamp string) (string, error) {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(layout), nil
+}
+
+// unixTime returns the RFC3339 timestamp as seconds since 1970-01-01
+func unixTime(timestamp string) (int64, error) {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+// e7toFloat converts a coordinate in E7 format to a float in degrees
+func e7toFloat(e7 IntString) (float64, error) {
+	return strconv.ParseFloat(E7toDec(e7), 64)
+}
+
+// round formats x with the given number of decimal digits
+func round(digits int, x float64) string {
+	return strconv.FormatFloat(x, 'f', digits, 64)
+}
+
+// staticBlock returns the output of the block executed without data, "" if it is not defined
+func staticBlock(tmpl *template.Template, name string) (string, error) {
+	t := tmpl.Lookup(name)
+	if t == nil {
+		return "", nil
+	}
+	var b strings.Builder
+	err := t.Execute(&b, nil)
+	return b.String(), err
+}
+
+// NewUserTemplateWriter returns a TemplateWriter using the blocks defined in the template text
+func NewUserTemplateWriter(w io.Writer, text string) (Writer, error) {
+	tmpl, err := template.New("user").Funcs(funcMap).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	location := tmpl.Lookup("location")
+	if location == nil {
+		return nil, fmt.Errorf("the template has no location block")
+	}
+	tw := &TemplateWriter{
+		w:        bufio.NewWriter(w),
+		location: location,
+		waypoint: tmpl.Lookup("waypoint"),
+		// the user templates expect the waypoints before the tracks
+		waypointsFirst: true,
+		// the user templates choose to use .Offset and .LocalTime
+		localTime: true,
+	}
+	// the other blocks don't depend on the data
+	for name, block := range map[string]*string{
+		"header":     &tw.header,
+		"trackStart": &tw.trackStart,
+		"newSegment": &tw.newSegment,
+		"newTrack":   &tw.newTrack,
+		"trackEnd":   &tw.trackEnd,
+		"footer":     &tw.footer,
+	} {
+		if *block, err = staticBlock(tmpl, name); err != nil {
+			return nil, err
+		}
+	}
+	return tw, nil
+}
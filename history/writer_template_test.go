@@ -0,0 +1,44 @@
+package history
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUserTemplateWriter(t *testing.T) {
+	text := `{{ define "header" }}BEGIN;
+{{ end }}{{ define "location" }}INSERT INTO points VALUES ({{ unix .Timestamp }}, '{{ date "2006-01-02 15:04" .Timestamp }}', {{ e7tofloat .LatitudeE7 | round 3 }}, {{ .LongitudeE7 | e7todec }});
+{{ end }}{{ define "newTrack" }}-- new track
+{{ end }}{{ define "footer" }}COMMIT;
+{{ end }}`
+	var b bytes.Buffer
+	w, err := NewUserTemplateWriter(&b, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteHeader()
+	w.WriteLocation(Location{LatitudeE7: "506443831", LongitudeE7: "30536723", Timestamp: "2024-12-07T16:44:25Z"})
+	w.WriteNewSegment()
+	w.WriteNewTrack()
+	w.WriteWaypoint(Waypoint{})
+	w.WriteLocation(Location{LatitudeE7: "-506443831", LongitudeE7: "30536723", Timestamp: "2024-12-07T16:45:25.5Z"})
+	w.WriteFooter()
+	w.Flush()
+
+	expected := `BEGIN;
+INSERT INTO points VALUES (1733589865, '2024-12-07 16:44', 50.644, 3.0536723);
+-- new track
+INSERT INTO points VALUES (1733589925, '2024-12-07 16:45', -50.644, 3.0536723);
+COMMIT;
+`
+	if b.String() != expected {
+		t.Errorf("user template output =\n%s", b.String())
+	}
+
+	if _, err := NewUserTemplateWriter(&b, `{{ define "header" }}{{ end }}`); err == nil {
+		t.Errorf("a template without location block should fail")
+	}
+	if _, err := NewUserTemplateWriter(&b, `{{ define "location" }}{{ .Foo }`); err == nil {
+		t.Errorf("an invalid template should fail")
+	}
+}
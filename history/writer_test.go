@@ -0,0 +1,128 @@
+package history
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_e7toDec(t *testing.T) {
+	data := []struct {
+		in  IntString
+		out string
+	}{
+		{"0987654321", "098.7654321"},
+		{"987654321", "98.7654321"},
+		{"87654321", "8.7654321"},
+		{"7654321", "0.7654321"},
+		{"54321", "0.0054321"},
+		{"-0987654321", "-098.7654321"},
+		{"-987654321", "-98.7654321"},
+		{"-87654321", "-8.7654321"},
+		{"-7654321", "-0.7654321"},
+		{"-54321", "-0.0054321"},
+		{"", "0.0000000"},
+	}
+
+	for _, d := range data {
+		if E7toDec(d.in) != d.out {
+			t.Errorf("E7toDec(%s) != %s", d.in, d.out)
+		}
+	}
+}
+
+func TestTemplateWriterWithoutLocation(t *testing.T) {
+	var b bytes.Buffer
+	w := NewGPXWriter(&b, false)
+	w.WriteHeader()
+	w.WriteNewTrack()
+	w.WriteFooter()
+	w.Flush()
+	if b.String() != gpxHeader+gpxFooter {
+		t.Errorf("empty GPX = %s", b.String())
+	}
+}
+
+func TestGPXWaypointsFirst(t *testing.T) {
+	var b bytes.Buffer
+	w := NewGPXWriter(&b, false)
+	w.WriteHeader()
+	w.WriteLocation(Location{LatitudeE7: "506443831", LongitudeE7: "30536723", Timestamp: "2024-12-07T16:44:25Z"})
+	w.WriteWaypoint(Waypoint{LatitudeE7: "506443831", LongitudeE7: "30536723", Arrival: "2024-12-07T16:44:25Z"})
+	w.WriteNewTrack()
+	w.WriteLocation(Location{LatitudeE7: "506443832", LongitudeE7: "30536723", Timestamp: "2024-12-07T17:44:25Z"})
+	w.WriteFooter()
+	w.Flush()
+
+	out := b.String()
+	wpt, trk := strings.Index(out, "<wpt "), strings.Index(out, "<trk>")
+	if wpt < 0 || trk < 0 || wpt > trk || strings.Count(out, "<trkpt ") != 2 || !strings.HasSuffix(out, gpxFooter) {
+		t.Errorf("the waypoints should be before the tracks in\n%s", out)
+	}
+}
+
+func TestLocalTimeOutputs(t *testing.T) {
+	l := Location{LatitudeE7: "506443831", LongitudeE7: "30536723", Accuracy: "13", Timestamp: "2024-12-07T16:46:25Z", Offset: "+01:00"}
+	data := []struct {
+		newWriter func(w io.Writer, localTime bool) Writer
+		localTime string
+		utc       string
+	}{
+		{NewCSVWriter, "activity,localtime\n2024-12-07T16:46:25Z,50.6443831,3.0536723,13,,,,,,,2024-12-07T17:46:25+01:00\n",
+			"activity\n2024-12-07T16:46:25Z,50.6443831,3.0536723,13,,,,,,\n"},
+		{NewGPXWriter, "<gotoextr:localtime>2024-12-07T17:46:25+01:00</gotoextr:localtime>", "<accuracy>13</accuracy>\n\t\t\t</trkpt>"},
+		{NewKMLWriter, "<when>2024-12-07T17:46:25+01:00</when>", "<when>2024-12-07T16:46:25Z</when>"},
+		{NewGeoJSONSeqWriter, `"localTime":"2024-12-07T17:46:25+01:00"`, `"accuracy":13}}`},
+	}
+
+	for _, d := range data {
+		for _, localTime := range []bool{true, false} {
+			var b bytes.Buffer
+			w := d.newWriter(&b, localTime)
+			w.WriteHeader()
+			w.WriteLocation(l)
+			w.WriteFooter()
+			w.Flush()
+			out := d.utc
+			if localTime {
+				out = d.localTime
+			}
+			if !strings.Contains(b.String(), out) {
+				t.Errorf("the output\n%s\nhas no %s", b.String(), out)
+			}
+		}
+	}
+}
+
+func TestMotionOutputs(t *testing.T) {
+	l := Location{LatitudeE7: "506443831", LongitudeE7: "30536723", Accuracy: "13", Timestamp: "2024-12-07T16:46:25Z",
+		Altitude: "65.3", Speed: "1.5", Heading: "271", VerticalAccuracy: "3"}
+	utc := func(newWriter func(w io.Writer, localTime bool) Writer) func(w io.Writer) Writer {
+		return func(w io.Writer) Writer { return newWriter(w, false) }
+	}
+	data := []struct {
+		newWriter func(w io.Writer) Writer
+		out       string
+	}{
+		{utc(NewCSVWriter), "2024-12-07T16:46:25Z,50.6443831,3.0536723,13,,65.3,1.5,271,3,\n"},
+		{utc(NewGPXWriter), "<ele>65.3</ele>"},
+		{utc(NewGPXWriter), "<gpxtpx:speed>1.5</gpxtpx:speed>\n\t\t\t\t\t\t<gpxtpx:course>271</gpxtpx:course>\n\t\t\t\t\t</gpxtpx:TrackPointExtension>\n\t\t\t\t\t<gotoextr:vaccuracy>3</gotoextr:vaccuracy>"},
+		{NewTCXWriter, "<AltitudeMeters>65.3</AltitudeMeters>"},
+		{utc(NewKMLWriter), "<coordinates>3.0536723,50.6443831,65.3</coordinates>"},
+		{utc(NewGeoJSONSeqWriter), `"coordinates":[3.0536723,50.6443831,65.3]`},
+		{utc(NewGeoJSONSeqWriter), `"speed":1.5,"heading":271,"verticalAccuracy":3`},
+	}
+
+	for _, d := range data {
+		var b bytes.Buffer
+		w := d.newWriter(&b)
+		w.WriteHeader()
+		w.WriteLocation(l)
+		w.WriteFooter()
+		w.Flush()
+		if !strings.Contains(b.String(), d.out) {
+			t.Errorf("the output\n%s\nhas no %s", b.String(), d.out)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kpym/gotoextr/history"
+)
+
+// input is the location history file to read
+type input struct {
+	name string
+	// semantic is true to also read the semantic location history files of the zip
+	// and the semantic segments of the phone export
+	semantic bool
+	// gap is the time without record from which the semantic locations are used
+	gap time.Duration
+	// strict is true to stop at the first malformed record
+	strict bool
+	// start and end are the dates to read, in YYYY-MM-DD format
+	start, end string
+
+	// the malformed records skipped by the readers
+	mu           sync.Mutex
+	skipped      int
+	firstSkipped error
+}
+
+// newReader returns a history reader of r that counts the malformed records of the input
+func (in *input) newReader(r io.Reader) *history.Reader {
+	rd := history.NewReader(bufio.NewReader(r))
+	rd.Strict = in.strict
+	rd.Segments, rd.Gap = in.semantic, in.gap
+	rd.OnSkip = func(err *history.RecordError) {
+		in.mu.Lock()
+		defer in.mu.Unlock()
+		if in.skipped == 0 {
+			in.firstSkipped = err
+		}
+		in.skipped++
+	}
+	return rd
+}
+
+// printSkipped prints the number of malformed records skipped, if any
+func (in *input) printSkipped(w io.Writer) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.skipped > 0 {
+		fmt.Fprintf(w, "Skipped %d malformed records (first: %v)\n", in.skipped, in.firstSkipped)
+	}
+}
+
+// read reads the input file and returns a channel of locations.
+// If the file is .zip the 'Records.json' file inside the zip is used,
+// and if semantic is true, also the Semantic Location History files
+// of the months between start and end.
+// The returned function should be called to close the input once all the locations
+// are received, it returns the error that stopped the reading, if any.
+func (in *input) read() (<-chan history.Location, func() error) {
+	inputname := in.name
+	// If the file is not a zip, it should be the Records.json file
+	// (or any other json file recognized by the history reader)
+	if !strings.HasSuffix(inputname, ".zip") {
+		file, err := os.Open(inputname)
+		check(err)
+		rd := in.newReader(file)
+		locations, err := rd.Locations()
+		if err != nil {
+			file.Close()
+			check(fmt.Errorf("reading '%s': %w", inputname, err))
+		}
+		return locations, func() error {
+			file.Close()
+			if err := rd.Err(); err != nil {
+				return fmt.Errorf("reading '%s': %w", inputname, err)
+			}
+			return nil
+		}
+	}
+
+	// The file is .zip, access the files inside the zip
+	zf, closeInput := openZip(inputname)
+	var records, months []*zip.File
+	for _, f := range zf.File {
+		if strings.HasSuffix(f.Name, "/Records.json") {
+			records = append(records, f)
+		}
+	}
+	if in.semantic {
+		months = semanticFiles(zf, in.start, in.end)
+	}
+	if len(records) == 0 && len(months) == 0 {
+		closeInput()
+		if in.semantic {
+			check(fmt.Errorf("no 'Records.json' nor semantic location history found in '%s'", inputname))
+		}
+		check(fmt.Errorf("file 'Records.json' not found in '%s'", inputname))
+	}
+	// only the first 'Records.json' is used
+	if len(records) > 1 {
+		records = records[:1]
+	}
+	locations, errRecords := in.readZipFiles(records)
+	errMonths := func() error { return nil }
+	if len(months) > 0 {
+		var monthLocations <-chan history.Location
+		monthLocations, errMonths = in.readZipFiles(months)
+		// the semantic locations only fill the gaps of the records
+		locations = history.MergeGaps(locations, monthLocations, in.gap)
+	}
+	return locations, func() error {
+		closeInput()
+		if err := errRecords(); err != nil {
+			return err
+		}
+		return errMonths()
+	}
+}
+
+// openZip opens the zip file.
+// The returned function should be called to close the zip.
+func openZip(inputname string) (*zip.Reader, func()) {
+	// try to read all the file in memory
+	content, err := os.ReadFile(inputname)
+	if err == nil {
+		// associate a zip reader to the content
+		zf, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		check(err)
+		return zf, func() {}
+	}
+	// Cant read entire file in memory, so open the zip file from disk
+	zfc, err := zip.OpenReader(inputname)
+	check(err)
+	return &zfc.Reader, func() { zfc.Close() }
+}
+
+// monthNumbers maps the month names used in the semantic file names to their number
+var monthNumbers = map[string]int{}
+
+func init() {
+	for m := time.January; m <= time.December; m++ {
+		monthNumbers[strings.ToUpper(m.String())] = int(m)
+	}
+}
+
+// semanticMonth returns the month in YYYY-MM format of a semantic location history
+// file name like 'Semantic Location History/2019/2019_JANUARY.json', or "" if the name doesn't match
+func semanticMonth(name string) string {
+	if !strings.Contains(name, "/Semantic Location History/") {
+		return ""
+	}
+	year, month, found := strings.Cut(strings.TrimSuffix(path.Base(name), ".json"), "_")
+	if !found || len(year) != 4 || monthNumbers[month] == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s-%02d", year, monthNumbers[month])
+}
+
+// semanticFiles returns the semantic location history files of the months
+// between start and end, in chronological order
+func semanticFiles(zf *zip.Reader, start, end string) []*zip.File {
+	var files []*zip.File
+	months := map[*zip.File]string{}
+	for _, f := range zf.File {
+		month := semanticMonth(f.Name)
+		if month == "" || month < start[:7] || month > end[:7] {
+			continue
+		}
+		months[f] = month
+		files = append(files, f)
+	}
+	sort.SliceStable(files, func(i, j int) bool { return months[files[i]] < months[files[j]] })
+	return files
+}
+
+// readZipFiles reads the files one after the other and returns a channel of all their locations.
+// The reading stops at the first error, given by the returned function once the channel is closed.
+func (in *input) readZipFiles(files []*zip.File) (<-chan history.Location, func() error) {
+	locations := make(chan history.Location, history.LocBufSize)
+	var err error
+	go func() {
+		defer close(locations)
+		for _, f := range files {
+			if err = in.readZipFile(f, locations); err != nil {
+				err = fmt.Errorf("reading '%s': %w", f.Name, err)
+				return
+			}
+		}
+	}()
+	return locations, func() error { return err }
+}
+
+// readZipFile sends the locations of the file to the locations channel
+func (in *input) readZipFile(f *zip.File, locations chan<- history.Location) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	rd := in.newReader(rc)
+	locs, err := rd.Locations()
+	if err != nil {
+		return err
+	}
+	for l := range locs {
+		locations <- l
+	}
+	return rd.Err()
+}
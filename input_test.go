@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSemanticMonth(t *testing.T) {
+	data := []struct {
+		in  string
+		out string
+	}{
+		{"Takeout/Location History/Semantic Location History/2019/2019_JANUARY.json", "2019-01"},
+		{"Takeout/Location History/Semantic Location History/2021/2021_DECEMBER.json", "2021-12"},
+		{"Takeout/Location History/Records.json", ""},
+		{"Takeout/Location History/Semantic Location History/2019/2019_JANVIER.json", ""},
+	}
+
+	for _, d := range data {
+		if got := semanticMonth(d.in); got != d.out {
+			t.Errorf("semanticMonth(%s) = %s != %s", d.in, got, d.out)
+		}
+	}
+}
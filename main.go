@@ -1,60 +1,18 @@
-// Before 2024
-// Read Records.json and extract history data.
-// The input date is json with the following format
-// {
-// "locations": [
-//
-//	{
-//	  "latitudeE7": 506553765,
-//	  "longitudeE7": 30632229,
-//	  "accuracy": 24,
-//	  "timestamp": "2012-01-27T21:14:42.352Z"
-//	  ...
-//	},
-//	  ...
-//
-// ]
-//
-// The file is very large, so we read it using json.Decoder.
-// If the file is .zip (smaller) we try to read it in memory.
-//
-// Since 2024.
-// We can export the location history from an Android device to a JSON file.
-// The JSON file has the following format:
-//
-//	{
-//	  ...
-//	  "rawSignals": [
-//	    {
-//	      ...
-//	      "position": {
-//	        "LatLng": "50.6443831°, 3.0536723°",
-//	        "accuracyMeters": 13,
-//	        "altitudeMeters": 65.30000305175781,
-//	        "source": "UNKNOWN",
-//	        "timestamp": "2024-12-07T17:46:25.000+01:00",
-//	        "speedMetersPerSecond": 0.0
-//	      }
-//	      ,
-//	      ...
-//	    }
-//	  ]
-//	}
+// gotoextr extracts the location history exported from Google Maps
+// to GPX, KML, TCX, CSV or NMEA files, and geotags photos with it.
+// The reading and the writing are done by the package history.
 package main
 
 import (
-	"archive/zip"
-	"bufio"
-	"bytes"
 	"fmt"
-	"io"
 	"os"
 	"strings"
 	"time"
+	_ "time/tzdata" // the time zones of --tz, even without zoneinfo on the system
 
 	"github.com/docopt/docopt-go"
-	"github.com/goccy/go-json"
 	"github.com/gosuri/uilive"
+	"github.com/kpym/gotoextr/history"
 )
 
 // The version that is set by goreleaser
@@ -65,258 +23,224 @@ var usage = "gotoextr [version: " + version + "]" + ` extract history data from
 
 Usage:
   gotoextr [-h] -s <start> [options] <input>
+  gotoextr geotag [options] <input> <photos>
+  gotoextr devices [options] <input>
   
 Options:
-  -h --help        Show this screen.
-  -s <start>       Start date in YYYY-MM-DD format
-  -e <end>         End date in YYYY-MM-DD format [default: <start>]
-  -a <accuracy>    Keeps only locations with accuracy less than <accuracy> meters [default: 40]
-  -t <tp>          New track if coordinates have less than <tp> digits in common [default: 1]
-  -g <sp>          New segment if coordinates have less than <sp> digits in common [default: 2]
-  -f <format>      Output format (gpx|kml|tcx|csv|nmea) [default: gpx]
-  -o <output>      Output file name [default: history_<start>_<end>.<format>]
-  <input>          Input file name (zip or json)
+  -h --help              Show this screen.
+  -s <start>             Start date in YYYY-MM-DD format, or date and time in YYYY-MM-DDThh:mm format
+  -e <end>               End date (included) or date and time (excluded) [default: <start>]
+  --tz <zone>            Time zone of the start and end, and of the photos without offset,
+                         IANA name (Asia/Tokyo) or local [default: UTC]
+  -a <accuracy>          Keeps only locations with accuracy less than <accuracy> meters [default: 40]
+  -t <tp>                New track if coordinates have less than <tp> digits in common,
+                         or are more than <tp> apart (500m, 2km, 30min, 2h),
+                         or if the activity changes (activity) [default: 1]
+  -g <sp>                New segment with the same rules as -t [default: 2]
+  -f <format>            Output format (gpx|kml|tcx|fit|csv|nmea|geojson|geojsonseq),
+                         or template:<file> to use the blocks of a template file,
+                         several comma separated formats are written in one pass [default: gpx]
+  -o <output>            Output file names, comma separated, one per format
+                         [default: history_<start>_<end>.<format>]
+  --semantic             Also read the Semantic Location History files from the zip,
+                         or the semantic segments of the phone export
+  --semantic-gap <min>   Use the semantic locations only more than <min> minutes from a record [default: 10]
+  --strict               Stop at the first malformed record instead of skipping it
+  --activity <list>      Keeps only the locations of the activities (still,walking,running,cycling,vehicle)
+  --device <list>        Keeps only the locations of the comma separated device tags
+  --source <list>        Keeps only the locations of the sources (gps,wifi,cell...)
+  --platform <list>      Keeps only the locations of the platforms (android,ios...)
+  --bbox <box>           Keeps only the locations in the box minLon,minLat,maxLon,maxLat
+  --within <file>        Keeps only the locations in the polygons of a GeoJSON file
+  --outside              Keeps the locations outside of --bbox or --within instead
+  --localtime            Also write the local times of the locations, when the offset is known
+  --split <period>       One output file per period (day|week|month), named from
+                         the output name where {date} is replaced by the period
+  --max-speed <kmh>      Remove the spikes reached faster than <kmh> km/h, comma separated speeds
+                         for all the locations (300) or an activity (cycling:60)
+  --smooth <method>      Smooth the segments with a Kalman filter (kalman or kalman:<speed in m/s>)
+                         or a moving median (median or median:<locations>)
+  --simplify <m>         Simplify the segments, removing the locations less than <m> meters from the simplified line
+  --interval <sec>       Keep in each segment only the locations at least <sec> seconds apart
+  --privacy <zones>      Privacy zones lat,lon,radius (in meters) separated by ;
+                         or a GeoJSON file of polygons
+  --privacy-mode <mode>  Drop the locations in the privacy zones, or snap them to the center (drop|snap) [default: drop]
+  --privacy-trim <m>     Also drop the <m> meters of track before entering and after leaving a zone [default: 0]
+  --stays <min>          Add waypoints for the places where we stayed at least <min> minutes
+  --stay-radius <m>      Maximal distance in meters from the arrival to stay in the same place [default: 100]
+  --clock <offset>       Camera clock offset, how much the camera is ahead of the real time [default: 0s]
+  --maxgap <min>         Maximal time gap in minutes between a photo and a location [default: 30]
+  --xmp                  Write a <photo>.<ext>.xmp sidecar with the GPS tags for each geotagged photo
+  --overwrite            Replace the existing XMP sidecars
+  --dry-run              Only list the XMP sidecars that would be written
+  <input>                Input file name (zip or json)
+  <photos>               JPEG file or folder containing JPEG files to geotag
 
 Examples:
   gotoextr -s 2012-01-01 -e 2012-01-31 -a 40 takeout.zip
+  gotoextr -s 2012-01-01T08:30 -e 2012-01-01T18:00 --tz Europe/Paris takeout.zip
+  gotoextr -s 2012-01-01 --stays 15 -f kml takeout.zip
+  gotoextr -s 2012-01-01 -f gpx,csv -o photos.gpx,points.csv takeout.zip
+  gotoextr -s 2012-01-01 -e 2012-12-31 --split month -o trips_{date}.gpx takeout.zip
+  gotoextr -s 2012-01-01 -t 5km,2h -g 500m,10min takeout.zip
+  gotoextr -s 2012-01-01 -t 2h,activity --activity cycling takeout.zip
+  gotoextr -s 2012-01-01 --bbox 2.22,48.81,2.47,48.91 takeout.zip
+  gotoextr -s 2012-01-01 --within home.geojson --outside takeout.zip
+  gotoextr -s 2012-01-01 --privacy "48.8566,2.3522,300" --privacy-trim 500 takeout.zip
+  gotoextr -s 2012-01-01 --max-speed 200,walking:15 takeout.zip
+  gotoextr -s 2012-01-01 -e 2012-01-31 --simplify 10 --interval 30 takeout.zip
+  gotoextr -s 2012-01-01 --smooth kalman:2 --activity walking takeout.zip
+  gotoextr geotag --clock 1m30s takeout.zip ./photos
+  gotoextr geotag --xmp --dry-run takeout.zip ./photos
+  gotoextr devices takeout.zip
 `
 
-// IntString is a string that can be unmarshalled from an int
-// This avoid number parsing
-type IntString string
-
-// Location is the struct for the location data
-type Location struct {
-	LatitudeE7  IntString `json:"latitudeE7"`
-	LongitudeE7 IntString `json:"longitudeE7"`
-	Accuracy    IntString `json:"accuracy"`
-	Timestamp   string    `json:"timestamp"`
-}
-
-// Json unmashalling for IntString
-func (i *IntString) UnmarshalJSON(data []byte) error {
-	*i = IntString(data)
-	return nil
-}
-
-type latlng struct {
-	Latitude  IntString
-	Longitude IntString
-}
-
-type posObject struct {
-	Position position `json:"position"`
-}
-
-type position struct {
-	LatLng    latlng    `json:"LatLng"`
-	Accuracy  IntString `json:"accuracyMeters"`
-	Timestamp string    `json:"timestamp"`
+// check is a helper function to check for errors
+func check(err error) {
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
 }
 
-// coordToIntString converts a string "XX.XXXXXXX°" to an IntString of E7 format
-func coordToIntString(s string) (IntString, error) {
-	// remove the °
-	s = strings.TrimSuffix(s, "°")
-	// split the string in two parts
-	parts := strings.Split(s, ".")
-	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid coord %s", s)
-	}
-	// normalize the integer part
-	if len(parts[0]) == 0 {
-		parts[0] = "0"
-	}
-	// cut/pad the decimal part to 7 digits
-	if len(parts[1]) == 7 {
-		// nothing to do
-	} else if len(parts[1]) > 7 {
-		parts[1] = parts[1][:7]
-	} else {
-		parts[1] += strings.Repeat("0", 7-len(parts[1]))
+// splitList returns the non empty elements of the comma separated list
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
 	}
-
-	// return the IntString
-	return IntString(parts[0] + parts[1]), nil
+	return list
 }
 
-// Json unmashalling for latlng from "XX.XXXXXXX°,YY.YYYYYYY°"
-func (ll *latlng) UnmarshalJSON(data []byte) error {
-	var s string
-	err := json.Unmarshal(data, &s)
-	if err != nil {
-		return err
-	}
-	parts := strings.Split(s, ",")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid LatLng %s", s)
+// parseFilter returns the filter of the accuracy, activity, device, source, platform and area options.
+// The time range is not set.
+func parseFilter(arguments docopt.Opts) history.Filter {
+	accuracy, err := arguments.String("-a")
+	check(err)
+	filter := history.Filter{Accuracy: accuracy}
+	if arguments["--activity"] != nil {
+		activities, err := arguments.String("--activity")
+		check(err)
+		filter.Activities, err = history.ParseActivities(activities)
+		check(err)
 	}
-	ll.Latitude, err = coordToIntString(parts[0])
-	if err != nil {
-		return err
+	for option, list := range map[string]*[]string{
+		"--device":   &filter.Devices,
+		"--source":   &filter.Sources,
+		"--platform": &filter.Platforms,
+	} {
+		if arguments[option] != nil {
+			s, err := arguments.String(option)
+			check(err)
+			*list = splitList(s)
+		}
 	}
-	ll.Longitude, err = coordToIntString(parts[1])
-	if err != nil {
-		return err
+	filter.Area = parseArea(arguments)
+	if filter.Area != nil {
+		filter.Outside, err = arguments.Bool("--outside")
+		check(err)
 	}
-	return nil
+	return filter
 }
 
-// toUTC convert RFC3339 to a string without the timezone ending with "Z"
-// example "2024-12-07T17:46:25.000+01:00" -> "2024-12-07T16:46:25.000Z"
-func toUTC(s string) string {
-	// if string do not contain a timezone (+ or -), return it
-	if !strings.ContainsAny(s, "+-") {
-		return s
+// parseZones returns the privacy zones of the --privacy option,
+// read from the GeoJSON file if it is a file name
+func parseZones(arguments docopt.Opts) []history.Zone {
+	zones, err := arguments.String("--privacy")
+	check(err)
+	if info, err := os.Stat(zones); err != nil || info.IsDir() {
+		list, err := history.ParseZones(zones)
+		check(err)
+		return list
 	}
-	t, err := time.Parse(time.RFC3339, s)
+	file, err := os.Open(zones)
+	check(err)
+	defer file.Close()
+	list, err := history.ReadGeoJSONZones(file)
 	if err != nil {
-		return s
+		check(fmt.Errorf("reading %s: %w", zones, err))
 	}
-	return t.UTC().Format(time.RFC3339)
+	return list
 }
 
-// toLocation converts a position to a Location
-func (p *position) toLocation() Location {
-	return Location{
-		LatitudeE7:  p.LatLng.Latitude,
-		LongitudeE7: p.LatLng.Longitude,
-		Accuracy:    p.Accuracy,
-		Timestamp:   toUTC(p.Timestamp),
+// parseArea returns the area of the --bbox or --within option, or nil if none is set
+func parseArea(arguments docopt.Opts) *history.Area {
+	if arguments["--bbox"] != nil && arguments["--within"] != nil {
+		check(fmt.Errorf("--bbox and --within can't be used together"))
 	}
-}
-
-// check is a helper function to check for errors
-func check(err error) {
-	if err != nil {
-		fmt.Println("Error:", err)
-		os.Exit(1)
+	if arguments["--bbox"] != nil {
+		bbox, err := arguments.String("--bbox")
+		check(err)
+		area, err := history.ParseBBox(bbox)
+		check(err)
+		return area
 	}
+	if arguments["--within"] != nil {
+		name, err := arguments.String("--within")
+		check(err)
+		file, err := os.Open(name)
+		check(err)
+		defer file.Close()
+		area, err := history.ReadGeoJSONArea(file)
+		if err != nil {
+			check(fmt.Errorf("reading %s: %w", name, err))
+		}
+		return area
+	}
+	return nil
 }
 
-func getOldLocation(decoder *json.Decoder) (loc Location, err error) {
-	err = decoder.Decode(&loc)
-	return loc, err
-}
-
-func getNewLocation(decoder *json.Decoder) (Location, error) {
-	var pos posObject
-	err := decoder.Decode(&pos)
-	if err != nil {
-		return Location{}, err
+// parseTZ returns the time zone of the IANA name, or the time zone of the computer for "local"
+func parseTZ(name string) (*time.Location, error) {
+	if strings.EqualFold(name, "local") {
+		return time.Local, nil
 	}
-	if pos.Position.Timestamp == "" {
-		return Location{}, fmt.Errorf("missing timestamp")
-	}
-	return pos.Position.toLocation(), nil
+	return time.LoadLocation(name)
 }
 
-// locBufSize is the size of the channel buffer for locations
-const locBufSize = 100
-
-// Read the input file and return a channel of locations
-func Read(reader io.Reader) chan Location {
-	// Create a decoder
-	decoder := json.NewDecoder(reader)
-
-	var version int
-	// Read up to the "locations" key
-	for {
-		t, err := decoder.Token()
-		check(err)
-		if t == "locations" {
-			// found the locations key, so old format
-			version = 1
-			break
-		}
-		if t == "rawSignals" {
-			// found the rawSignals key, so new format
-			version = 2
-			break
+// parseBound parses the start or the end of the range in the time zone tz,
+// a date in YYYY-MM-DD format or a date and time in YYYY-MM-DDThh:mm[:ss] format.
+// The end of a date is the start of the next day, so that the whole day is included.
+func parseBound(s string, tz *time.Location, end bool) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, tz); err == nil {
+			return t, nil
 		}
 	}
-
-	// old or new format
-	var getLocation func(*json.Decoder) (Location, error)
-	switch version {
-	case 1:
-		getLocation = getOldLocation
-	case 2:
-		getLocation = getNewLocation
-	default:
-		check(fmt.Errorf("unknown json version"))
+	t, err := time.ParseInLocation("2006-01-02", s, tz)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or YYYY-MM-DDThh:mm", s)
 	}
-
-	// Read the array start
-	t, err := decoder.Token()
-	check(err)
-	if delim, ok := t.(json.Delim); !ok || delim != '[' {
-		check(fmt.Errorf("expected array start, got %T: %v", t, t))
+	if end {
+		t = t.AddDate(0, 0, 1)
 	}
-
-	// Create a channel to send the locations
-	locations := make(chan Location, locBufSize)
-
-	// Start a goroutine to read the array
-	go func() {
-		// start reading the array
-		for decoder.More() {
-			loc, err := getLocation(decoder)
-			// skip invalid locations
-			if err == nil {
-				locations <- loc
-			}
-		}
-		// Close the channel when done
-		close(locations)
-	}()
-
-	return locations
+	return t, nil
 }
 
-// header, locFormat, newTrack, newSegment, footer are the parts of the gpx file
+// utcBound returns t in UTC, to be compared with the UTC timestamps of the locations.
+// The zone is omitted, so that 2023-07-14T08:30:00.500Z is after the bound 2023-07-14T08:30:00.
+func utcBound(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05")
+}
 
-// nextDay returns the next day in YYYY-MM-DD format
-func nextDay(date string) string {
-	t, err := time.Parse("2006-01-02", date)
+func main() {
+	// Parse the command line
+	arguments, err := docopt.ParseDoc(usage)
 	check(err)
-	return t.AddDate(0, 0, 1).Format("2006-01-02")
-}
 
-// acceptAccuracy returns true if the accuracy is less than max
-// it works with strings to avoid number parsing
-func acceptAccuracy(a IntString, max string) bool {
-	if len(a) != len(max) {
-		return len(a) <= len(max)
+	// geotag photos instead of extracting the history
+	if cmd, _ := arguments.Bool("geotag"); cmd {
+		geotag(arguments)
+		return
 	}
-	return string(a) <= max
-}
-
-// sameDigits returns the number of digits in common between two coordinates
-// for example 987654321 which represent 98.7654321 and 987650321 which represent 98.7650321
-// have 3 decimal digits (after the point) in common
-func sameDigits(a, b IntString) int {
-	na := len(a)
-	nb := len(b)
-	if na != nb || na < 7 {
-		// the coordinates are not the same length or invalid IntString
-		return 0
-	}
-	if a[:na-7] != b[:nb-7] {
-		// the integer part is different
-		return 0
-	}
-	n := 0
-	for i := na - 7; i < na; i++ {
-		if a[i] != b[i] {
-			break
-		}
-		n++
+	// list the devices instead of extracting the history
+	if cmd, _ := arguments.Bool("devices"); cmd {
+		devices(arguments)
+		return
 	}
-	return n
-}
 
-func main() {
 	// strtup time
 	now := time.Now()
 	// new terminal writer
@@ -333,154 +257,238 @@ func main() {
 		fmt.Fprintf(writer.Newline(), "Wrote %d positions in %d segments in %d tracks\n", w, s, t)
 	}
 
-	// Parse the command line
-	arguments, err := docopt.ParseDoc(usage)
-	check(err)
-
 	// get the arguments
+	tzname, err := arguments.String("--tz")
+	check(err)
+	tz, err := parseTZ(tzname)
+	check(err)
 	start, err := arguments.String("-s")
 	check(err)
 	if arguments["-e"] == "<start>" {
-		arguments["-e"] = arguments["-s"]
+		// up to the end of the start day
+		date, _, _ := strings.Cut(start, "T")
+		arguments["-e"] = date
 	}
 	end, err := arguments.String("-e")
 	check(err)
-	endNext := nextDay(end)
-	accuracy, err := arguments.String("-a")
+	from, err := parseBound(start, tz, false)
+	check(err)
+	to, err := parseBound(end, tz, true)
+	check(err)
+	if !from.Before(to) {
+		check(fmt.Errorf("the end %s is not after the start %s", end, start))
+	}
+	filter := parseFilter(arguments)
+	filter.Start, filter.End = utcBound(from), utcBound(to)
+	tp, err := arguments.String("-t")
 	check(err)
-	tp, err := arguments.Int("-t")
+	trackRules, err := history.ParseSplitRules(tp)
 	check(err)
-	sp, err := arguments.Int("-g")
+	sp, err := arguments.String("-g")
+	check(err)
+	segmentRules, err := history.ParseSplitRules(sp)
 	check(err)
 	inputname, err := arguments.String("<input>")
 	check(err)
 	outputname, err := arguments.String("-o")
 	check(err)
+	if outputname == "history_<start>_<end>.<format>" {
+		outputname = ""
+	}
 	format, err := arguments.String("-f")
 	check(err)
-	format = strings.ToLower(format)
-	// if format is not one of the allowed, exit
-	switch format {
-	case "gpx", "kml", "tcx", "csv", "nmea": // ok
-	default:
-		check(fmt.Errorf("unknown format %s", format))
+	var period history.Period
+	if arguments["--split"] != nil {
+		split, err := arguments.String("--split")
+		check(err)
+		period, err = history.ParsePeriod(split, tz)
+		check(err)
+	}
+	outputs, err := parseOutputs(format, outputname, start, end, period != nil)
+	check(err)
+	localTime, err := arguments.Bool("--localtime")
+	check(err)
+	for _, o := range outputs {
+		o.localTime = localTime
 	}
-	if outputname == "history_<start>_<end>.<format>" {
-		if start == end {
-			outputname = fmt.Sprintf("history_%s.%s", start, format)
-		} else {
-			outputname = fmt.Sprintf("history_%s_%s.%s", start, end, format)
+
+	semantic, err := arguments.Bool("--semantic")
+	check(err)
+	gap, err := arguments.Int("--semantic-gap")
+	check(err)
+	strict, err := arguments.Bool("--strict")
+	check(err)
+
+	// the stays options
+	var stayTime int
+	var stayRadius float64
+	if arguments["--stays"] != nil {
+		stayTime, err = arguments.Int("--stays")
+		check(err)
+		stayRadius, err = arguments.Float64("--stay-radius")
+		check(err)
+	}
+
+	// the maximal speeds to remove the spikes
+	var maxSpeeds map[string]float64
+	if arguments["--max-speed"] != nil {
+		speeds, err := arguments.String("--max-speed")
+		check(err)
+		maxSpeeds, err = history.ParseMaxSpeeds(speeds)
+		check(err)
+	}
+
+	// the smoothing option
+	var smoothing history.Smoothing
+	if arguments["--smooth"] != nil {
+		smooth, err := arguments.String("--smooth")
+		check(err)
+		smoothing, err = history.ParseSmoothing(smooth)
+		check(err)
+	}
+
+	// the simplification options
+	var tolerance, interval float64
+	if arguments["--simplify"] != nil {
+		tolerance, err = arguments.Float64("--simplify")
+		check(err)
+	}
+	if arguments["--interval"] != nil {
+		interval, err = arguments.Float64("--interval")
+		check(err)
+	}
+
+	// the privacy options
+	var zones []history.Zone
+	var snap bool
+	var trim float64
+	if arguments["--privacy"] != nil {
+		zones = parseZones(arguments)
+		mode, err := arguments.String("--privacy-mode")
+		check(err)
+		switch mode {
+		case "drop", "snap":
+			snap = mode == "snap"
+		default:
+			check(fmt.Errorf("unknown privacy mode %q, expected drop or snap", mode))
 		}
+		trim, err = arguments.Float64("--privacy-trim")
+		check(err)
 	}
 
-	// The input reader
-	var input io.Reader
-	// If the file is .zip, access the file inside the zip
-	if strings.HasSuffix(inputname, ".zip") {
-		// try to read all the file in memory
-		var zf *zip.Reader
-		content, err := os.ReadFile(inputname)
-		if err == nil {
-			// associate a zip reader to the content
-			zf, err = zip.NewReader(bytes.NewReader(content), int64(len(content)))
-			check(err)
-		} else {
-			// Cant read entire file in memory, so open the zip file from disk
-			zfc, err := zip.OpenReader(inputname)
-			check(err)
-			defer zfc.Close()
-			zf = &zfc.Reader
+	// Read the locations
+	// the UTC dates of the range, to select the semantic files
+	utcStart, utcEnd := from.UTC().Format("2006-01-02"), to.Add(-time.Second).UTC().Format("2006-01-02")
+	in := &input{name: inputname, semantic: semantic, gap: time.Duration(gap) * time.Minute, strict: strict, start: utcStart, end: utcEnd}
+	locations, closeInput := in.read()
+
+	// fail removes the partial outputs and exits on error
+	fail := func(err error) {
+		if err != nil {
+			var names []string
+			for _, o := range outputs {
+				names = append(names, "'"+o.current()+"'")
+				o.abort()
+			}
+			check(fmt.Errorf("%s not written: %w", strings.Join(names, ", "), err))
 		}
-		// Find the file inside the zip
-		for _, f := range zf.File {
-			if strings.HasSuffix(f.Name, "/Records.json") {
-				input, err = f.Open()
-				check(err)
-				break
+	}
+
+	// openOutputs opens the output files of the date, written to temporary files
+	// renamed by commitOutputs, and returns the writer to all of them
+	openOutputs := func(date string) (history.Writer, error) {
+		var writers []history.Writer
+		for _, o := range outputs {
+			w, err := o.open(date)
+			if err != nil {
+				return nil, err
 			}
+			writers = append(writers, w)
 		}
-		if input == nil {
-			check(fmt.Errorf("file 'Records.json' not found in '%s'", inputname))
+		if len(writers) > 1 {
+			return history.MultiWriter(writers...), nil
 		}
-	} else {
-		// if the file is not a zip, it should be the Records.json file
-		file, err := os.Open(inputname)
-		check(err)
-		defer file.Close()
-		input = file
+		return writers[0], nil
+	}
+	// commitOutputs replaces the output files by the written ones
+	commitOutputs := func() error {
+		for _, o := range outputs {
+			if err := o.commit(); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	// Create a new reader.
-	reader := bufio.NewReader(input)
-
-	// Read the locations
-	locations := Read(reader)
+	// Open the output files, or a new set of files for each period
+	var output history.Writer
+	if period != nil {
+		output = history.NewSplitter(period, openOutputs, commitOutputs)
+	} else {
+		output, err = openOutputs("")
+		fail(err)
+	}
 
-	// Open the output file
-	outfile, err := os.Create(outputname)
-	check(err)
-	defer outfile.Close()
-
-	// Create a new writer
-	var output Writer
-	switch format {
-	case "gpx":
-		output = NewGPXWriter(outfile)
-	case "kml":
-		output = NewKMLWriter(outfile)
-	case "tcx":
-		output = NewTCXWriter(outfile)
-	case "csv":
-		output = NewCSVWriter(outfile)
-	case "nmea":
-		output = NewNMEAWriter(outfile)
-	default:
-		// this should never happen
-		panic(fmt.Errorf("unknown format %s, this should be verified before", format))
-	}
-	defer output.Flush()
+	// Count the positions, segments and tracks written
+	counter := history.NewCounter(output)
+	output = counter
+	// Reduce the number of locations of the segments
+	if tolerance > 0 || interval > 0 {
+		output = history.NewSimplifier(output, tolerance, time.Duration(interval*float64(time.Second)))
+	}
+	// Detect the stays on all the locations
+	if stayTime > 0 {
+		output = history.NewStayDetector(output, stayRadius, time.Duration(stayTime)*time.Minute)
+	}
+	// Smooth the coordinates of the segments
+	if smoothing != nil {
+		output = history.NewSmoother(output, smoothing)
+	}
+	// Hide the privacy zones, on the raw coordinates before the smoothing
+	if len(zones) > 0 {
+		output = history.NewPrivacyFilter(output, zones, snap, trim)
+	}
+	// Start new segments and tracks when the positions are too far apart
+	output = history.NewSegmenter(output, trackRules, segmentRules)
+	// Remove the spikes before they split the tracks
+	var spikes *history.SpeedFilter
+	if maxSpeeds != nil {
+		spikes = history.NewSpeedFilter(output, maxSpeeds)
+		output = spikes
+	}
 
 	// Write the header
-	output.WriteHeader()
+	fail(output.WriteHeader())
 
-	// Some counters :
 	// r : number of positions read
-	// w : number of positions written
-	// t : number of tracks written
-	// s : number of segments written
-	r, w, t, s := 0, 0, 1, 1
-	// the last position used to detect new segments and tracks
-	var lastLat, lastLon IntString
+	r := 0
 	// loop over the locations
 	for l := range locations {
 		r++
 		// check if the location is in the time range and has the required accuracy
-		if l.Timestamp >= start && l.Timestamp < endNext && acceptAccuracy(l.Accuracy, accuracy) {
-			if w > 0 {
-				// if it is not the first location, check if the distance from the previous one
-				// requires a new segment or a new track
-				if sameDigits(lastLat, l.LatitudeE7) < tp || sameDigits(lastLon, l.LongitudeE7) < tp {
-					t++
-					s++
-					output.WriteNewTrack()
-				} else if sameDigits(lastLat, l.LatitudeE7) < sp || sameDigits(lastLon, l.LongitudeE7) < sp {
-					s++
-					output.WriteNewSegment()
-				}
-			}
-			w++
-			lastLat, lastLon = l.LatitudeE7, l.LongitudeE7
+		if filter.Accept(l) {
 			// write the location in the output file
-			output.WriteLocation(l)
+			fail(output.WriteLocation(l))
 		}
 		// display the progress every 0x8000=32768 records
 		if r&0x7fff == 0 {
-			print(r, w, s, t, l.Timestamp, time.Since(now).Seconds())
+			print(r, counter.Locations, counter.Segments, counter.Tracks, l.Timestamp, time.Since(now).Seconds())
 		}
 	}
-	// Write the footer
-	output.WriteFooter()
+	// Stop on a reading error
+	fail(closeInput())
+
+	// Write the footer and replace the output files
+	fail(output.WriteFooter())
+	fail(output.Flush())
+	if period == nil {
+		fail(commitOutputs())
+	}
 
 	// The end
-	print(r, w, s, t, "", time.Since(now).Seconds())
+	print(r, counter.Locations, counter.Segments, counter.Tracks, "", time.Since(now).Seconds())
+	if spikes != nil && spikes.Removed > 0 {
+		fmt.Fprintf(writer.Newline(), "Removed %d spikes\n", spikes.Removed)
+	}
+	in.printSkipped(writer.Newline())
 }
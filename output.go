@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kpym/gotoextr/history"
+)
+
+// outputFile is an output of the command line
+type outputFile struct {
+	// format is the name of the format, "template" for a user template
+	format string
+	// template is the text of the user template
+	template string
+	// name is the file name, where {date} is replaced by the period when split
+	name string
+	// file is the open file, nil if none
+	file *atomicFile
+	// localTime is true to write the local times of the locations
+	localTime bool
+}
+
+// parseOutputs returns the outputs of the comma separated formats and file names.
+// Without names, the default names history_<start>.<ext> or history_<start>_<end>.<ext> are used,
+// or history_{date}.<ext> if the output is split in periods.
+func parseOutputs(formats, names, start, end string, split bool) ([]*outputFile, error) {
+	// ':' of the times is not allowed in the Windows file names
+	start, end = strings.ReplaceAll(start, ":", ""), strings.ReplaceAll(end, ":", "")
+	var outputs []*outputFile
+	for _, format := range strings.Split(formats, ",") {
+		format = strings.TrimSpace(format)
+		o := &outputFile{}
+		// ext is the extension of the default output file name
+		var ext string
+		if path, found := strings.CutPrefix(format, "template:"); found {
+			// a user template file
+			text, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			o.template = string(text)
+			if _, err := history.NewUserTemplateWriter(io.Discard, o.template); err != nil {
+				return nil, fmt.Errorf("template '%s': %w", path, err)
+			}
+			o.format, ext = "template", templateExt(path)
+		} else {
+			o.format = strings.ToLower(format)
+			// if format is not one of the allowed, exit
+			switch o.format {
+			case "gpx", "kml", "tcx", "fit", "csv", "nmea", "geojson", "geojsonseq": // ok
+			default:
+				return nil, fmt.Errorf("unknown format %s", o.format)
+			}
+			ext = o.format
+		}
+		if split {
+			o.name = fmt.Sprintf("history_{date}.%s", ext)
+		} else if start == end {
+			o.name = fmt.Sprintf("history_%s.%s", start, ext)
+		} else {
+			o.name = fmt.Sprintf("history_%s_%s.%s", start, end, ext)
+		}
+		outputs = append(outputs, o)
+	}
+	if names != "" {
+		list := strings.Split(names, ",")
+		if len(list) != len(outputs) {
+			return nil, fmt.Errorf("%d output names for %d formats", len(list), len(outputs))
+		}
+		for i, name := range list {
+			outputs[i].name = strings.TrimSpace(name)
+		}
+	}
+	// the same file can't be written twice
+	for i, o := range outputs {
+		if split && !strings.Contains(o.name, "{date}") {
+			return nil, fmt.Errorf("output '%s' should contain {date} to be split", o.name)
+		}
+		for _, other := range outputs[:i] {
+			if o.name == other.name {
+				return nil, fmt.Errorf("output '%s' used twice", o.name)
+			}
+		}
+	}
+	return outputs, nil
+}
+
+// templateExt returns the extension of the output of a template file,
+// for example "sql" for "inserts.sql.tmpl", or "txt" if there is none
+func templateExt(path string) string {
+	ext := filepath.Ext(strings.TrimSuffix(filepath.Base(path), ".tmpl"))
+	if ext == "" {
+		return "txt"
+	}
+	return ext[1:]
+}
+
+// newWriter returns the writer of the output format writing to w
+func (o *outputFile) newWriter(w io.Writer) history.Writer {
+	switch o.format {
+	case "gpx":
+		return history.NewGPXWriter(w, o.localTime)
+	case "kml":
+		return history.NewKMLWriter(w, o.localTime)
+	case "tcx":
+		return history.NewTCXWriter(w)
+	case "fit":
+		return history.NewFITWriter(w)
+	case "csv":
+		return history.NewCSVWriter(w, o.localTime)
+	case "nmea":
+		return history.NewNMEAWriter(w)
+	case "geojson":
+		return history.NewGeoJSONWriter(w)
+	case "geojsonseq":
+		return history.NewGeoJSONSeqWriter(w, o.localTime)
+	case "template":
+		// the template is already checked
+		tw, _ := history.NewUserTemplateWriter(w, o.template)
+		return tw
+	}
+	// this should never happen
+	panic(fmt.Errorf("unknown format %s, this should be verified before", o.format))
+}
+
+// open creates the output file of the date, written to a temporary file renamed by commit,
+// and returns its writer
+func (o *outputFile) open(date string) (history.Writer, error) {
+	file, err := createAtomic(strings.ReplaceAll(o.name, "{date}", date))
+	if err != nil {
+		return nil, err
+	}
+	o.file = file
+	return o.newWriter(file), nil
+}
+
+// commit replaces the output file by the written one
+func (o *outputFile) commit() error {
+	if err := o.file.Commit(); err != nil {
+		return err
+	}
+	o.file = nil
+	return nil
+}
+
+// abort removes the written file, if any
+func (o *outputFile) abort() {
+	if o.file != nil {
+		o.file.Abort()
+		o.file = nil
+	}
+}
+
+// current returns the name of the open file, or the name of the output if none is open
+func (o *outputFile) current() string {
+	if o.file != nil {
+		return o.file.name
+	}
+	return o.name
+}
+
+// atomicFile is an output file written to a temporary file in the same directory,
+// that replaces the destination only when Commit is called
+type atomicFile struct {
+	*os.File
+	name string
+	// direct is true if the destination is written directly (a symlink, a device or a pipe)
+	direct bool
+	// mode is the mode of the replaced file, kept on Commit if replace is true
+	mode    os.FileMode
+	replace bool
+}
+
+// createAtomic creates the temporary file for the output file name.
+// An existing destination that is not a regular file (a symlink, a device or a pipe)
+// is written directly, like os.Create does.
+func createAtomic(name string) (*atomicFile, error) {
+	fi, err := os.Lstat(name)
+	if err == nil && !fi.Mode().IsRegular() {
+		file, err := os.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		return &atomicFile{File: file, name: name, direct: true}, nil
+	}
+	// a new file gets the mode 0666 less the umask, as with os.Create
+	f := &atomicFile{name: name, mode: 0o666}
+	if err == nil {
+		f.mode, f.replace = fi.Mode().Perm(), true
+	}
+	dir, base := filepath.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+	for try := 0; ; try++ {
+		tmp := filepath.Join(dir, "."+base+"."+strconv.FormatInt(rand.Int63(), 36)+".tmp")
+		f.File, err = os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_EXCL, f.mode)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsExist(err) || try == 100 {
+			return nil, err
+		}
+	}
+}
+
+// Commit syncs and closes the temporary file and renames it to the output file name,
+// so that the output file is never left truncated by a crash.
+// On failure the temporary file is removed.
+func (f *atomicFile) Commit() error {
+	if f.direct {
+		return f.Close()
+	}
+	var err error
+	if f.replace {
+		err = f.Chmod(f.mode)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), f.name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Abort closes and removes the temporary file, the output file is untouched
+func (f *atomicFile) Abort() {
+	f.Close()
+	if !f.direct {
+		os.Remove(f.Name())
+	}
+}
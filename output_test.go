@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAtomicFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "out.gpx")
+	if err := os.WriteFile(name, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// an aborted output leaves the existing file untouched
+	f, err := createAtomic(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("partial")
+	f.Abort()
+	if got, _ := os.ReadFile(name); string(got) != "old" {
+		t.Errorf("aborted output changed the file to %q", got)
+	}
+
+	// a committed output replaces the file
+	f, err = createAtomic(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("new")
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(name); string(got) != "new" {
+		t.Errorf("committed output is %q", got)
+	}
+
+	// no temporary file is left
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files in the output directory", len(entries))
+	}
+}
+
+func TestAtomicFileSymlinkAndMode(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.csv")
+	link := filepath.Join(dir, "link.csv")
+	if err := os.WriteFile(target, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the mode of an existing file is kept
+	f, err := createAtomic(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("new")
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(target); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("committed output mode is %v (%v)", fi.Mode().Perm(), err)
+	}
+
+	// a symlink is written through, not replaced
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("no symlinks:", err)
+	}
+	f, err = createAtomic(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("linked")
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("the symlink was replaced")
+	}
+	if got, _ := os.ReadFile(target); string(got) != "linked" {
+		t.Errorf("the symlink target is %q", got)
+	}
+}
+
+func TestTemplateExt(t *testing.T) {
+	data := []struct {
+		in  string
+		out string
+	}{
+		{"inserts.sql.tmpl", "sql"},
+		{"dir.v2/points.csv.tmpl", "csv"},
+		{"custom.tmpl", "txt"},
+		{"custom", "txt"},
+		{"points.json", "json"},
+	}
+
+	for _, d := range data {
+		if got := templateExt(d.in); got != d.out {
+			t.Errorf("templateExt(%s) = %s != %s", d.in, got, d.out)
+		}
+	}
+}
+
+func TestParseOutputs(t *testing.T) {
+	data := []struct {
+		formats, names string
+		split          bool
+		out            []string
+	}{
+		{"gpx", "", false, []string{"gpx history_2023-01-01.gpx"}},
+		{"GPX,csv", "", false, []string{"gpx history_2023-01-01.gpx", "csv history_2023-01-01.csv"}},
+		{"gpx,csv", "a.gpx,b.txt", false, []string{"gpx a.gpx", "csv b.txt"}},
+		{"gpx,csv", "a.gpx", false, nil},
+		{"gpx,gpx", "", false, nil},
+		{"xml", "", false, nil},
+		{"template:nonexistent.tmpl", "", false, nil},
+		{"gpx,csv", "", true, []string{"gpx history_{date}.gpx", "csv history_{date}.csv"}},
+		{"gpx", "trip_{date}.gpx", true, []string{"gpx trip_{date}.gpx"}},
+		{"gpx", "trip.gpx", true, nil},
+	}
+
+	for _, d := range data {
+		outputs, err := parseOutputs(d.formats, d.names, "2023-01-01", "2023-01-01", d.split)
+		var got []string
+		for _, o := range outputs {
+			got = append(got, o.format+" "+o.name)
+		}
+		if (err == nil) != (d.out != nil) || strings.Join(got, ",") != strings.Join(d.out, ",") {
+			t.Errorf("parseOutputs(%s, %s, %t) = %v, %v != %v", d.formats, d.names, d.split, got, err, d.out)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/kpym/gotoextr/history"
+)
+
+const (
+	xmpTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/" x:xmptk="gotoextr">
+	<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+		<rdf:Description rdf:about=""
+			xmlns:exif="http://ns.adobe.com/exif/1.0/"
+			xmlns:gotoextr="https://github.com/kpym/gotoextr/ns/1.0/"
+			exif:GPSVersionID="2.2.0.0"
+			exif:GPSMapDatum="WGS-84"
+			exif:GPSLatitude="{{ xmpcoord .LatitudeE7 "N" "S" }}"
+			exif:GPSLongitude="{{ xmpcoord .LongitudeE7 "E" "W" }}"
+			exif:GPSTimeStamp="{{ .Timestamp }}"
+			gotoextr:Accuracy="{{ .Accuracy }}"/>
+	</rdf:RDF>
+</x:xmpmeta>
+`
+)
+
+// xmpTmpl is the compiled XMP sidecar template
+var xmpTmpl *template.Template
+
+func init() {
+	funcMap := template.FuncMap{"xmpcoord": xmpCoord}
+	xmpTmpl = template.Must(template.New("xmp").Funcs(funcMap).Parse(xmpTemplate))
+}
+
+// xmpCoord converts an E7 coordinate to the XMP "DDD,MM.mmmmmmmR" format,
+// where R is pos for positive coordinates and neg for negative ones
+func xmpCoord(e7 history.IntString, pos, neg string) (string, error) {
+	c, err := e7.Int64()
+	if err != nil {
+		return "", err
+	}
+	ref := pos
+	if c < 0 {
+		c, ref = -c, neg
+	}
+	// the minutes multiplied by 1e7
+	min := c % 10000000 * 60
+	return fmt.Sprintf("%d,%02d.%07d%s", c/10000000, min/10000000, min%10000000, ref), nil
+}
+
+// sidecarName returns the name of the XMP sidecar of a photo, like IMG_0001.JPG.xmp,
+// that is read by Darktable and doesn't collide for IMG_0001.jpg and IMG_0001.jpeg
+func sidecarName(path string) string {
+	return path + ".xmp"
+}
+
+// writeSidecar writes the XMP sidecar with the location l to path.
+// An existing sidecar is replaced only if overwrite is true.
+// On failure the written file is removed.
+func writeSidecar(path string, l history.Location, overwrite bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !overwrite {
+		flags |= os.O_EXCL
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	err = xmpTmpl.Execute(file, l)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
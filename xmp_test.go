@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kpym/gotoextr/history"
+)
+
+func TestXMPCoord(t *testing.T) {
+	data := []struct {
+		in  history.IntString
+		out string
+	}{
+		{"506553765", "50,39.3225900N"},
+		{"30632229", "3,03.7933740N"},
+		{"-30632229", "3,03.7933740S"},
+		{"-1401500000", "140,09.0000000S"},
+		{"0", "0,00.0000000N"},
+	}
+
+	for _, d := range data {
+		got, err := xmpCoord(d.in, "N", "S")
+		if err != nil || got != d.out {
+			t.Errorf("xmpCoord(%s) = %s, %v != %s", d.in, got, err, d.out)
+		}
+	}
+}
+
+func TestSidecarName(t *testing.T) {
+	data := []struct {
+		in  string
+		out string
+	}{
+		{"photos/IMG_0001.JPG", "photos/IMG_0001.JPG.xmp"},
+		{"IMG.0001.jpeg", "IMG.0001.jpeg.xmp"},
+	}
+
+	for _, d := range data {
+		if got := sidecarName(d.in); got != d.out {
+			t.Errorf("sidecarName(%s) = %s != %s", d.in, got, d.out)
+		}
+	}
+}
+
+func TestWriteSidecarFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "IMG_0001.JPG.xmp")
+	if err := writeSidecar(path, history.Location{LatitudeE7: "north", LongitudeE7: "30536723"}, false); err == nil {
+		t.Fatalf("writeSidecar with an invalid latitude should fail")
+	}
+	// the partial sidecar is removed, so that it doesn't block the next try
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the partial sidecar %s is left", path)
+	}
+	if err := writeSidecar(path, history.Location{LatitudeE7: "506443831", LongitudeE7: "30536723"}, false); err != nil {
+		t.Errorf("writeSidecar error: %v", err)
+	}
+}